@@ -6,8 +6,6 @@ import (
 	"github.com/domano/fundament/internal/native"
 )
 
-var nativeCheckAvailability = native.CheckAvailability
-
 // AvailabilityState indicates whether the on-device model is ready to serve.
 type AvailabilityState int
 
@@ -47,10 +45,10 @@ func (a Availability) String() string {
 
 // CheckAvailability queries the Swift shim for the current availability status.
 func CheckAvailability() (Availability, error) {
-	meta, err := nativeCheckAvailability()
-	if err != nil {
-		return Availability{}, err
-	}
+	return NativeBackend().CheckAvailability()
+}
+
+func availabilityFromNative(meta native.Availability) Availability {
 	state := AvailabilityUnknown
 	if meta.State == 1 {
 		state = AvailabilityReady
@@ -75,5 +73,5 @@ func CheckAvailability() (Availability, error) {
 	return Availability{
 		State:  state,
 		Reason: reason,
-	}, nil
+	}
 }
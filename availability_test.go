@@ -7,16 +7,6 @@ import (
 	"github.com/domano/fundament/internal/native"
 )
 
-func withAvailabilityHook(fn func() (native.Availability, error)) func() {
-	prev := nativeCheckAvailability
-	if fn != nil {
-		nativeCheckAvailability = fn
-	}
-	return func() {
-		nativeCheckAvailability = prev
-	}
-}
-
 func TestAvailabilityString(t *testing.T) {
 	ready := Availability{State: AvailabilityReady}
 	if got := ready.String(); got != "available" {
@@ -64,10 +54,8 @@ func TestCheckAvailabilityMapping(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			restore := withAvailabilityHook(tc.stub)
-			t.Cleanup(restore)
-
-			got, err := CheckAvailability()
+			backend := nativeBackend{api: &nativeAPI{checkAvailability: tc.stub}}
+			got, err := backend.CheckAvailability()
 			if tc.wantErr {
 				if err == nil {
 					t.Fatal("expected error")
@@ -0,0 +1,299 @@
+package fundament
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/domano/fundament/internal/native"
+)
+
+// Backend creates model sessions and reports model availability.
+// The default implementation bridges to SystemLanguageModel through the Swift shim.
+type Backend interface {
+	NewSession(instructions string) (BackendSession, error)
+	CheckAvailability() (Availability, error)
+}
+
+// BackendSession is a stateful conversation hosted by a Backend.
+// Session serialises access and validates inputs before calling into it.
+type BackendSession interface {
+	Respond(ctx context.Context, prompt string, opts GenerationOptions) (Response, error)
+	RespondStructured(ctx context.Context, prompt string, schema Schema, opts GenerationOptions) (StructuredResponse, error)
+	// RespondStream invokes fn for every chunk and returns once the final chunk was delivered.
+	RespondStream(ctx context.Context, prompt string, opts GenerationOptions, fn func(StreamChunk)) error
+	Close() error
+}
+
+// Prewarmer is implemented by backend sessions that can load model resources ahead of the
+// first request. Session.Prewarm is a no-op for sessions that lack it. Session does not queue
+// Prewarm behind its calls, so a session that cannot serve both at once must order them itself.
+type Prewarmer interface {
+	Prewarm(ctx context.Context, promptPrefix string) error
+}
+
+// NativeBackend returns the Backend bound to the on-device SystemLanguageModel.
+func NativeBackend() Backend {
+	return nativeBackend{api: shimAPI()}
+}
+
+// nativeAPI holds the shim entry points a native backend calls, so tests can hand a backend
+// stubs without touching the shim or any package state.
+type nativeAPI struct {
+	checkAvailability        func() (native.Availability, error)
+	createSession            func(instructions string) (native.SessionRef, error)
+	createSessionFromHistory func(transcript string) (native.SessionRef, error)
+	destroySession           func(native.SessionRef)
+	prewarm                  func(ref native.SessionRef, promptPrefix string) error
+	respond                  func(ref native.SessionRef, prompt, opts string, token native.CancelToken) (string, string, error)
+	respondStructured        func(ref native.SessionRef, prompt, schema, opts string, token native.CancelToken) (string, string, error)
+	stream                   func(ref native.SessionRef, prompt, opts string, token native.CancelToken, cb native.StreamCallback) (string, error)
+	createToken              func() native.CancelToken
+	cancelToken              func(native.CancelToken)
+	destroyToken             func(native.CancelToken)
+}
+
+func shimAPI() *nativeAPI {
+	return &nativeAPI{
+		checkAvailability:        native.CheckAvailability,
+		createSession:            native.SessionCreate,
+		createSessionFromHistory: native.SessionCreateWithTranscript,
+		destroySession:           native.SessionDestroy,
+		prewarm:                  native.SessionPrewarm,
+		respond:                  native.SessionRespond,
+		respondStructured:        native.SessionRespondStructured,
+		stream:                   native.SessionStream,
+		createToken:              native.CancelTokenCreate,
+		cancelToken:              native.CancelTokenCancel,
+		destroyToken:             native.CancelTokenDestroy,
+	}
+}
+
+type nativeBackend struct {
+	api *nativeAPI
+}
+
+func (b nativeBackend) NewSession(instructions string) (BackendSession, error) {
+	ref, err := b.api.createSession(instructions)
+	if err != nil {
+		return nil, err
+	}
+	return newNativeSession(b.api, ref), nil
+}
+
+func (b nativeBackend) NewSessionFromTranscript(t Transcript) (BackendSession, error) {
+	blob, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	ref, err := b.api.createSessionFromHistory(string(blob))
+	if err != nil {
+		return nil, err
+	}
+	return newNativeSession(b.api, ref), nil
+}
+
+func (b nativeBackend) CheckAvailability() (Availability, error) {
+	meta, err := b.api.checkAvailability()
+	if err != nil {
+		return Availability{}, err
+	}
+	return availabilityFromNative(meta), nil
+}
+
+// nativeSession owns a shim session handle. Calls that outlive their context keep the handle
+// alive until they return, so Close defers the destroy to the last of them.
+type nativeSession struct {
+	api      *nativeAPI
+	mu       sync.Mutex
+	ref      native.SessionRef
+	inflight int
+	closed   bool
+	// slot admits one shim call at a time, including abandoned calls that are still unwinding,
+	// because LanguageModelSession rejects concurrent requests.
+	slot chan struct{}
+}
+
+func newNativeSession(api *nativeAPI, ref native.SessionRef) *nativeSession {
+	return &nativeSession{api: api, ref: ref, slot: make(chan struct{}, 1)}
+}
+
+func (n *nativeSession) begin() (native.SessionRef, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed || n.ref == nil {
+		return nil, ErrSessionClosed
+	}
+	n.inflight++
+	return n.ref, nil
+}
+
+func (n *nativeSession) end() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.inflight--
+	n.release()
+}
+
+// release destroys the handle once closed and idle. Callers hold n.mu.
+func (n *nativeSession) release() {
+	if n.closed && n.inflight == 0 && n.ref != nil {
+		n.api.destroySession(n.ref)
+		n.ref = nil
+	}
+}
+
+// run performs a blocking shim call on its own goroutine so that ctx can abandon it.
+// When ctx ends first the Swift task is cancelled through the token and run returns
+// ctx.Err() at once; the late result is discarded and its buffers are freed by the
+// shim binding when the call unwinds.
+func (n *nativeSession) run(ctx context.Context, call func(native.SessionRef, native.CancelToken) error) error {
+	select {
+	case n.slot <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	ref, err := n.begin()
+	if err != nil {
+		<-n.slot
+		return err
+	}
+	var (
+		tokenMu sync.Mutex
+		token   = n.api.createToken()
+		done    = make(chan error, 1)
+	)
+	go func() {
+		err := call(ref, token)
+		tokenMu.Lock()
+		n.api.destroyToken(token)
+		token = nil
+		tokenMu.Unlock()
+		n.end()
+		<-n.slot
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		tokenMu.Lock()
+		if token != nil {
+			n.api.cancelToken(token)
+		}
+		tokenMu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Prewarm takes the call slot like a request, so it never overlaps one on the shim session.
+func (n *nativeSession) Prewarm(ctx context.Context, promptPrefix string) error {
+	return n.run(ctx, func(ref native.SessionRef, _ native.CancelToken) error {
+		return n.api.prewarm(ref, promptPrefix)
+	})
+}
+
+func (n *nativeSession) Respond(ctx context.Context, prompt string, opts GenerationOptions) (Response, error) {
+	blob, err := marshalGenerationOptions(opts)
+	if err != nil {
+		return Response{}, err
+	}
+	var text, meta string
+	err = n.run(ctx, func(ref native.SessionRef, token native.CancelToken) error {
+		var err error
+		text, meta, err = n.api.respond(ref, prompt, blob, token)
+		return err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{Text: text, Warnings: decodeWarnings(meta)}, nil
+}
+
+func (n *nativeSession) RespondStructured(ctx context.Context, prompt string, schema Schema, opts GenerationOptions) (StructuredResponse, error) {
+	blob, err := marshalGenerationOptions(opts)
+	if err != nil {
+		return StructuredResponse{}, err
+	}
+	var text, meta string
+	err = n.run(ctx, func(ref native.SessionRef, token native.CancelToken) error {
+		var err error
+		text, meta, err = n.api.respondStructured(ref, prompt, schema.String(), blob, token)
+		return err
+	})
+	if err != nil {
+		return StructuredResponse{}, err
+	}
+	return StructuredResponse{JSON: []byte(text), Warnings: decodeWarnings(meta)}, nil
+}
+
+func (n *nativeSession) RespondStream(ctx context.Context, prompt string, opts GenerationOptions, fn func(StreamChunk)) error {
+	if fn == nil {
+		return errors.New("fundament: stream callback must not be nil")
+	}
+	blob, err := marshalGenerationOptions(opts)
+	if err != nil {
+		return err
+	}
+	// Chunks arriving after ctx ended are dropped; the lock guarantees fn is never
+	// running once RespondStream has returned. The final chunk is held back until the
+	// call returns so it can carry the warnings reported with the response.
+	var (
+		deliverMu sync.Mutex
+		abandoned bool
+		final     *StreamChunk
+		meta      string
+	)
+	err = n.run(ctx, func(ref native.SessionRef, token native.CancelToken) error {
+		var err error
+		meta, err = n.api.stream(ref, prompt, blob, token, func(text string, last bool) {
+			deliverMu.Lock()
+			defer deliverMu.Unlock()
+			switch {
+			case abandoned:
+			case last:
+				final = &StreamChunk{Text: text, Final: true}
+			default:
+				fn(StreamChunk{Text: text})
+			}
+		})
+		return err
+	})
+	deliverMu.Lock()
+	abandoned = true
+	deliverMu.Unlock()
+	if err != nil {
+		return err
+	}
+	if final == nil {
+		final = &StreamChunk{Final: true}
+	}
+	final.Warnings = decodeWarnings(meta)
+	fn(*final)
+	return nil
+}
+
+// responseMetadata is the JSON the shim reports alongside a response.
+type responseMetadata struct {
+	Warnings []string `json:"warnings"`
+}
+
+func decodeWarnings(blob string) []string {
+	if blob == "" {
+		return nil
+	}
+	var meta responseMetadata
+	if err := json.Unmarshal([]byte(blob), &meta); err != nil {
+		return []string{"fundament: malformed response metadata: " + err.Error()}
+	}
+	return meta.Warnings
+}
+
+func (n *nativeSession) Close() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.closed = true
+	n.release()
+	return nil
+}
@@ -0,0 +1,393 @@
+// Package ollama implements a fundament.Backend on top of Ollama's native /api/chat endpoint.
+//
+// Streaming uses Ollama's NDJSON responses and RespondStructured passes the schema,
+// translated to JSON Schema, through the "format" field. Conversation history is kept
+// client-side per session.
+package ollama
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// DefaultBaseURL is the address Ollama listens on by default.
+const DefaultBaseURL = "http://localhost:11434"
+
+// DefaultAvailabilityTimeout bounds CheckAvailability when Config.AvailabilityTimeout is unset.
+const DefaultAvailabilityTimeout = 5 * time.Second
+
+// Config configures the backend.
+type Config struct {
+	// BaseURL is the server root, without the /api segment.
+	BaseURL string
+	// Model names the pulled model to chat with, e.g. "llama3.2" or "qwen2.5:7b".
+	Model string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// AvailabilityTimeout bounds CheckAvailability. It defaults to DefaultAvailabilityTimeout.
+	AvailabilityTimeout time.Duration
+}
+
+// Backend talks to an Ollama server.
+type Backend struct {
+	cfg Config
+}
+
+// New returns a Backend for cfg.
+func New(cfg Config) *Backend {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.AvailabilityTimeout <= 0 {
+		cfg.AvailabilityTimeout = DefaultAvailabilityTimeout
+	}
+	return &Backend{cfg: cfg}
+}
+
+// APIError reports an error returned by the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.StatusCode == 0 {
+		return "ollama: " + e.Message
+	}
+	return fmt.Sprintf("ollama: server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the failure is transient (rate limiting or a server-side error),
+// which lets fundament.Router fall back to another backend.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NewSession implements fundament.Backend.
+func (b *Backend) NewSession(instructions string) (fundament.BackendSession, error) {
+	if b.cfg.Model == "" {
+		return nil, errors.New("ollama: Config.Model is required")
+	}
+	s := &session{backend: b}
+	if instructions != "" {
+		s.messages = append(s.messages, message{Role: "system", Content: instructions})
+	}
+	return s, nil
+}
+
+// NewSessionFromTranscript implements fundament.TranscriptBackend by replaying the transcript
+// as chat history. Tool call entries are not supported.
+func (b *Backend) NewSessionFromTranscript(t fundament.Transcript) (fundament.BackendSession, error) {
+	s, err := b.NewSession("")
+	if err != nil {
+		return nil, err
+	}
+	messages, err := messagesFromTranscript(t)
+	if err != nil {
+		return nil, err
+	}
+	s.(*session).messages = messages
+	return s, nil
+}
+
+func messagesFromTranscript(t fundament.Transcript) ([]message, error) {
+	messages := make([]message, 0, len(t.Entries))
+	for _, e := range t.Entries {
+		switch e.Kind {
+		case fundament.EntryInstructions:
+			messages = append(messages, message{Role: "system", Content: e.Text})
+		case fundament.EntryPrompt:
+			messages = append(messages, message{Role: "user", Content: e.Text})
+		case fundament.EntryResponse:
+			messages = append(messages, message{Role: "assistant", Content: e.Text})
+		case fundament.EntryStructuredResponse:
+			messages = append(messages, message{Role: "assistant", Content: string(e.JSON)})
+		default:
+			return nil, fmt.Errorf("ollama: transcript entries of kind %q are not supported", e.Kind)
+		}
+	}
+	return messages, nil
+}
+
+// CheckAvailability implements fundament.Backend. It pings the server through /api/tags
+// and reports AvailabilityReasonModelNotReady when the configured model has not been pulled.
+func (b *Backend) CheckAvailability() (fundament.Availability, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.AvailabilityTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.cfg.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return fundament.Availability{}, err
+	}
+	resp, err := b.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fundament.Availability{}, fmt.Errorf("ollama: availability check: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fundament.Availability{}, decodeAPIError(resp)
+	}
+	var tags struct {
+		Models []struct {
+			Name  string `json:"name"`
+			Model string `json:"model"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return fundament.Availability{}, fmt.Errorf("ollama: decode tags: %w", err)
+	}
+	for _, m := range tags.Models {
+		if sameModel(m.Name, b.cfg.Model) || sameModel(m.Model, b.cfg.Model) {
+			return fundament.Availability{State: fundament.AvailabilityReady, Reason: fundament.AvailabilityReasonNone}, nil
+		}
+	}
+	return fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonModelNotReady}, nil
+}
+
+// sameModel compares model references, treating a missing tag as ":latest".
+func sameModel(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if !strings.Contains(a, ":") {
+		a += ":latest"
+	}
+	if !strings.Contains(b, ":") {
+		b += ":latest"
+	}
+	return a == b
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model    string          `json:"model"`
+	Messages []message       `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  *modelOptions   `json:"options,omitempty"`
+}
+
+type modelOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Seed        *uint64  `json:"seed,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+type chatResponse struct {
+	Message message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error"`
+}
+
+type session struct {
+	backend  *Backend
+	mu       sync.Mutex
+	messages []message
+}
+
+func (s *session) Respond(ctx context.Context, prompt string, opts fundament.GenerationOptions) (fundament.Response, error) {
+	text, err := s.complete(ctx, prompt, opts, nil)
+	if err != nil {
+		return fundament.Response{}, err
+	}
+	return fundament.Response{Text: text}, nil
+}
+
+func (s *session) RespondStructured(ctx context.Context, prompt string, schema fundament.Schema, opts fundament.GenerationOptions) (fundament.StructuredResponse, error) {
+	format, err := schema.JSONSchema()
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	text, err := s.complete(ctx, prompt, opts, format)
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	return fundament.StructuredResponse{JSON: json.RawMessage(text)}, nil
+}
+
+func (s *session) complete(ctx context.Context, prompt string, opts fundament.GenerationOptions, format json.RawMessage) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req := s.request(prompt, opts)
+	req.Format = format
+	resp, err := s.backend.post(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var decoded chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", fmt.Errorf("ollama: decode response: %w", err)
+	}
+	if decoded.Error != "" {
+		return "", &APIError{Message: decoded.Error}
+	}
+	s.commit(prompt, decoded.Message.Content)
+	return decoded.Message.Content, nil
+}
+
+func (s *session) RespondStream(ctx context.Context, prompt string, opts fundament.GenerationOptions, fn func(fundament.StreamChunk)) error {
+	if fn == nil {
+		return errors.New("ollama: stream callback must not be nil")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req := s.request(prompt, opts)
+	req.Stream = true
+	resp, err := s.backend.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Hold back one chunk so the last one can be flagged final when "done" arrives.
+	var (
+		full    strings.Builder
+		pending string
+		started bool
+		done    bool
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var event chatResponse
+		if err := json.Unmarshal(line, &event); err != nil {
+			return fmt.Errorf("ollama: decode stream event: %w", err)
+		}
+		if event.Error != "" {
+			return &APIError{Message: event.Error}
+		}
+		if event.Message.Content != "" {
+			if started {
+				fn(fundament.StreamChunk{Text: pending})
+			}
+			pending = event.Message.Content
+			started = true
+			full.WriteString(pending)
+		}
+		if event.Done {
+			done = true
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ollama: read stream: %w", err)
+	}
+	if !done {
+		return errors.New("ollama: stream ended before the final event")
+	}
+	fn(fundament.StreamChunk{Text: pending, Final: true})
+	s.commit(prompt, full.String())
+	return nil
+}
+
+// Prewarm loads the model into memory by sending a chat request without messages.
+func (s *session) Prewarm(ctx context.Context, _ string) error {
+	resp, err := s.backend.post(ctx, chatRequest{Model: s.backend.cfg.Model, Messages: []message{}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	return nil
+}
+
+// request builds a chat request for the history plus prompt. Callers hold s.mu.
+func (s *session) request(prompt string, opts fundament.GenerationOptions) chatRequest {
+	messages := make([]message, 0, len(s.messages)+1)
+	messages = append(messages, s.messages...)
+	messages = append(messages, message{Role: "user", Content: prompt})
+	opts = opts.Flatten()
+	req := chatRequest{
+		Model:    s.backend.cfg.Model,
+		Messages: messages,
+	}
+	mo := modelOptions{
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		TopK:        opts.TopK,
+		NumPredict:  opts.MaxTokens,
+		Seed:        opts.Seed,
+		Stop:        opts.StopSequences,
+	}
+	if !reflect.DeepEqual(mo, modelOptions{}) {
+		req.Options = &mo
+	}
+	return req
+}
+
+// commit appends a completed turn to the history. Callers hold s.mu.
+func (s *session) commit(prompt, reply string) {
+	s.messages = append(s.messages,
+		message{Role: "user", Content: prompt},
+		message{Role: "assistant", Content: reply},
+	)
+}
+
+func (b *Backend) post(ctx context.Context, body chatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.BaseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := b.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, decodeAPIError(resp)
+	}
+	return resp, nil
+}
+
+func decodeAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var payload struct {
+		Error string `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &payload) == nil && payload.Error != "" {
+		message = payload.Error
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
@@ -0,0 +1,375 @@
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// fakeServer records chat requests and replies with the handler supplied per test.
+type fakeServer struct {
+	mu       sync.Mutex
+	requests []chatRequest
+	reply    func(w http.ResponseWriter, req chatRequest)
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/tags":
+		fmt.Fprint(w, `{"models":[{"name":"llama3.2:latest","model":"llama3.2:latest"},{"name":"qwen2.5:7b","model":"qwen2.5:7b"}]}`)
+	case "/api/chat":
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+		f.reply(w, req)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeServer) last() chatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func newTestSession(t *testing.T, fake *fakeServer, instructions string) *fundament.Session {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	session, err := fundament.NewSession(fundament.SessionOptions{
+		Instructions: instructions,
+		Backend:      New(Config{BaseURL: srv.URL, Model: "llama3.2"}),
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func replyText(w http.ResponseWriter, text string) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"model":   "llama3.2",
+		"message": map[string]string{"role": "assistant", "content": text},
+		"done":    true,
+	})
+}
+
+func TestRespondKeepsHistoryAndMapsOptions(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		replyText(w, "reply to "+req.Messages[len(req.Messages)-1].Content)
+	}}
+	session := newTestSession(t, fake, "be brief")
+
+	resp, err := session.Respond(context.Background(), "first",
+		fundament.WithTemperature(0.4), fundament.WithTopK(20), fundament.WithMaxTokens(50), fundament.WithSeed(3))
+	if err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if resp.Text != "reply to first" {
+		t.Fatalf("unexpected response %q", resp.Text)
+	}
+	req := fake.last()
+	if req.Stream || req.Model != "llama3.2" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if req.Options == nil || *req.Options.Temperature != 0.4 || *req.Options.TopK != 20 || *req.Options.NumPredict != 50 || *req.Options.Seed != 3 {
+		t.Fatalf("options not mapped: %+v", req.Options)
+	}
+
+	if _, err := session.Respond(context.Background(), "second"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	req = fake.last()
+	if req.Options != nil {
+		t.Fatalf("expected no options, got %+v", req.Options)
+	}
+	want := []message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "reply to first"},
+		{Role: "user", Content: "second"},
+	}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), req.Messages)
+	}
+	for i := range want {
+		if req.Messages[i] != want[i] {
+			t.Fatalf("message %d = %+v, want %+v", i, req.Messages[i], want[i])
+		}
+	}
+}
+
+func TestRespondStructuredSendsFormat(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		replyText(w, `{"title":"Dune","tags":["sci-fi","classic"]}`)
+	}}
+	session := newTestSession(t, fake, "")
+
+	schema, err := fundament.SchemaFromRawJSON([]byte(`{
+		"name": "Book",
+		"properties": [
+			{"name": "title", "schema": {"type": "string"}},
+			{"name": "tags", "schema": {"type": "array", "minimumElements": 1, "items": {"type": "string"}}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	res, err := session.RespondStructured(context.Background(), "recommend", schema)
+	if err != nil {
+		t.Fatalf("RespondStructured error: %v", err)
+	}
+	if string(res.JSON) != `{"title":"Dune","tags":["sci-fi","classic"]}` {
+		t.Fatalf("unexpected JSON %s", res.JSON)
+	}
+
+	want, err := schema.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema error: %v", err)
+	}
+	if got := fake.last().Format; string(got) != string(want) {
+		t.Fatalf("unexpected format\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestRespondStreamNDJSON(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			replyText(w, "ok")
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, part := range []string{"Once", " upon", " a time"} {
+			enc.Encode(map[string]any{"message": map[string]string{"role": "assistant", "content": part}, "done": false})
+			w.(http.Flusher).Flush()
+		}
+		enc.Encode(map[string]any{"message": map[string]string{"role": "assistant", "content": ""}, "done": true})
+	}}
+	session := newTestSession(t, fake, "")
+
+	ch, err := session.RespondStream(context.Background(), "story")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var got []fundament.StreamChunk
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		got = append(got, chunk)
+	}
+	if len(got) != 3 || got[0].Text != "Once" || got[0].Final || got[2].Text != " a time" || !got[2].Final {
+		t.Fatalf("unexpected chunks %+v", got)
+	}
+
+	if _, err := session.Respond(context.Background(), "more"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if req := fake.last(); len(req.Messages) != 3 || req.Messages[1].Content != "Once upon a time" {
+		t.Fatalf("streamed turn missing from history: %+v", req.Messages)
+	}
+}
+
+func TestRespondStreamErrorEvent(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"par"},"done":false}`)
+		fmt.Fprintln(w, `{"error":"out of memory"}`)
+	}}
+	session := newTestSession(t, fake, "")
+
+	ch, err := session.RespondStream(context.Background(), "story")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var last fundament.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	var apiErr *APIError
+	if !errors.As(last.Err, &apiErr) || apiErr.Message != "out of memory" {
+		t.Fatalf("expected APIError chunk, got %+v", last)
+	}
+}
+
+func TestDoneReasonIsNotReportedAsFinishStop(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Sure."},"done":true,"done_reason":"stop"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Sure."},"done":false}`)
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop"}`)
+	}}
+	session := newTestSession(t, fake, "")
+
+	// "stop" also ends every natural reply, so it cannot mean that a marker matched.
+	resp, err := session.Respond(context.Background(), "hi", fundament.WithStopSequences("\nUser:"))
+	if err != nil || resp.Text != "Sure." || resp.FinishReason != "" {
+		t.Fatalf("a natural end must not report a reason, got %+v, %v", resp, err)
+	}
+
+	ch, err := session.RespondStream(context.Background(), "hi", fundament.WithStopSequences("\nUser:"))
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var last fundament.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	if !last.Final || last.FinishReason != "" {
+		t.Fatalf("a natural end must not report a reason on the final chunk, got %+v", last)
+	}
+}
+
+func TestRespondStreamTruncated(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			replyText(w, "ok")
+			return
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":"Once"},"done":false}`)
+	}}
+	session := newTestSession(t, fake, "")
+
+	ch, err := session.RespondStream(context.Background(), "story")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var last fundament.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	if last.Err == nil {
+		t.Fatalf("expected an error for a stream without a final event, got %+v", last)
+	}
+
+	if _, err := session.Respond(context.Background(), "again"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if req := fake.last(); len(req.Messages) != 1 {
+		t.Fatalf("truncated turn leaked into history: %+v", req.Messages)
+	}
+}
+
+func TestRespondHTTPError(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model \"llama3.2\" not found, try pulling it first"}`)
+	}}
+	session := newTestSession(t, fake, "")
+
+	_, err := session.Respond(context.Background(), "hi")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 APIError, got %v", err)
+	}
+}
+
+func TestCheckAvailability(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{})
+	defer srv.Close()
+
+	cases := []struct {
+		model string
+		want  fundament.Availability
+	}{
+		{"llama3.2", fundament.Availability{State: fundament.AvailabilityReady, Reason: fundament.AvailabilityReasonNone}},
+		{"qwen2.5:7b", fundament.Availability{State: fundament.AvailabilityReady, Reason: fundament.AvailabilityReasonNone}},
+		{"qwen2.5", fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonModelNotReady}},
+	}
+	for _, tc := range cases {
+		got, err := New(Config{BaseURL: srv.URL, Model: tc.model}).CheckAvailability()
+		if err != nil {
+			t.Fatalf("%s: CheckAvailability error: %v", tc.model, err)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %+v, got %+v", tc.model, tc.want, got)
+		}
+	}
+
+	srv.Close()
+	if _, err := New(Config{BaseURL: srv.URL, Model: "llama3.2"}).CheckAvailability(); err == nil {
+		t.Fatal("expected error when the server is unreachable")
+	}
+}
+
+func TestCheckAvailabilityTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer stalled.Close()
+	defer close(release)
+
+	_, err := New(Config{BaseURL: stalled.URL, Model: "llama3.2", AvailabilityTimeout: 20 * time.Millisecond}).CheckAvailability()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the check to time out, got %v", err)
+	}
+}
+
+func TestNewSessionRequiresModel(t *testing.T) {
+	if _, err := New(Config{}).NewSession(""); err == nil {
+		t.Fatal("expected error without a model")
+	}
+}
+
+func TestNewSessionFromTranscriptSeedsHistory(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		replyText(w, "Ada")
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	transcript := &fundament.Transcript{Entries: []fundament.TranscriptEntry{
+		{Kind: fundament.EntryPrompt, Text: "my name is Ada"},
+		{Kind: fundament.EntryResponse, Text: "Hi Ada"},
+	}}
+	session, err := fundament.NewSession(fundament.SessionOptions{
+		Instructions: "be brief",
+		Backend:      New(Config{BaseURL: srv.URL, Model: "llama3.2"}),
+		Transcript:   transcript,
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	if _, err := session.Respond(context.Background(), "name?"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	req := fake.last()
+	if len(req.Messages) != 4 || req.Messages[0] != (message{Role: "system", Content: "be brief"}) || req.Messages[2] != (message{Role: "assistant", Content: "Hi Ada"}) {
+		t.Fatalf("history not seeded: %+v", req.Messages)
+	}
+}
+
+func TestPrewarmLoadsModel(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		replyText(w, "")
+	}}
+	session := newTestSession(t, fake, "")
+	if err := session.Prewarm(context.Background(), ""); err != nil {
+		t.Fatalf("Prewarm error: %v", err)
+	}
+	if req := fake.last(); req.Model != "llama3.2" || len(req.Messages) != 0 {
+		t.Fatalf("expected an empty load request, got %+v", req)
+	}
+}
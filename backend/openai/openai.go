@@ -0,0 +1,428 @@
+// Package openai implements a fundament.Backend for servers that speak the OpenAI
+// /v1/chat/completions protocol, such as llama.cpp, vLLM, or LM Studio.
+//
+// Conversation history is kept client-side per session, so multi-turn behaviour matches
+// the stateful LanguageModelSession used by the native backend.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// DefaultBaseURL points at a llama.cpp server running with default settings.
+const DefaultBaseURL = "http://localhost:8080/v1"
+
+// DefaultAvailabilityTimeout bounds CheckAvailability when Config.AvailabilityTimeout is unset.
+const DefaultAvailabilityTimeout = 5 * time.Second
+
+// Config configures the backend.
+type Config struct {
+	// BaseURL is the API root including the version segment, e.g. "http://localhost:8000/v1".
+	BaseURL string
+	// APIKey is sent as a bearer token when set.
+	APIKey string
+	// Model names the model to request. Single-model servers usually ignore it.
+	Model string
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// AvailabilityTimeout bounds CheckAvailability. It defaults to DefaultAvailabilityTimeout.
+	AvailabilityTimeout time.Duration
+}
+
+// Backend talks to an OpenAI-compatible chat completions endpoint.
+type Backend struct {
+	cfg Config
+}
+
+// New returns a Backend for cfg.
+func New(cfg Config) *Backend {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = DefaultBaseURL
+	}
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.AvailabilityTimeout <= 0 {
+		cfg.AvailabilityTimeout = DefaultAvailabilityTimeout
+	}
+	return &Backend{cfg: cfg}
+}
+
+// APIError reports a non-2xx response from the server.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("openai: server returned %d", e.StatusCode)
+	}
+	return fmt.Sprintf("openai: server returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Retryable reports whether the failure is transient (rate limiting or a server-side error),
+// which lets fundament.Router fall back to another backend.
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NewSession implements fundament.Backend.
+func (b *Backend) NewSession(instructions string) (fundament.BackendSession, error) {
+	s := &session{backend: b}
+	if instructions != "" {
+		s.messages = append(s.messages, message{Role: "system", Content: instructions})
+	}
+	return s, nil
+}
+
+// NewSessionFromTranscript implements fundament.TranscriptBackend by replaying the transcript
+// as chat history. Tool call entries are not supported.
+func (b *Backend) NewSessionFromTranscript(t fundament.Transcript) (fundament.BackendSession, error) {
+	s, err := b.NewSession("")
+	if err != nil {
+		return nil, err
+	}
+	messages, err := messagesFromTranscript(t)
+	if err != nil {
+		return nil, err
+	}
+	s.(*session).messages = messages
+	return s, nil
+}
+
+func messagesFromTranscript(t fundament.Transcript) ([]message, error) {
+	messages := make([]message, 0, len(t.Entries))
+	for _, e := range t.Entries {
+		switch e.Kind {
+		case fundament.EntryInstructions:
+			messages = append(messages, message{Role: "system", Content: e.Text})
+		case fundament.EntryPrompt:
+			messages = append(messages, message{Role: "user", Content: e.Text})
+		case fundament.EntryResponse:
+			messages = append(messages, message{Role: "assistant", Content: e.Text})
+		case fundament.EntryStructuredResponse:
+			messages = append(messages, message{Role: "assistant", Content: string(e.JSON)})
+		default:
+			return nil, fmt.Errorf("openai: transcript entries of kind %q are not supported", e.Kind)
+		}
+	}
+	return messages, nil
+}
+
+// CheckAvailability implements fundament.Backend by listing the server's models.
+// A server that is still loading (503) or does not list the configured model reports
+// AvailabilityReasonModelNotReady.
+func (b *Backend) CheckAvailability() (fundament.Availability, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.AvailabilityTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.cfg.BaseURL+"/models", nil)
+	if err != nil {
+		return fundament.Availability{}, err
+	}
+	b.authorize(req)
+	resp, err := b.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fundament.Availability{}, fmt.Errorf("openai: availability check: %w", err)
+	}
+	defer resp.Body.Close()
+
+	notReady := fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonModelNotReady}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return notReady, nil
+	}
+	if resp.StatusCode/100 != 2 {
+		return fundament.Availability{}, decodeAPIError(resp)
+	}
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return fundament.Availability{}, fmt.Errorf("openai: decode models: %w", err)
+	}
+	if b.cfg.Model != "" && len(list.Data) > 0 {
+		found := false
+		for _, m := range list.Data {
+			if m.ID == b.cfg.Model {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return notReady, nil
+		}
+	}
+	return fundament.Availability{State: fundament.AvailabilityReady, Reason: fundament.AvailabilityReasonNone}, nil
+}
+
+func (b *Backend) authorize(req *http.Request) {
+	if b.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.cfg.APIKey)
+	}
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model          string          `json:"model,omitempty"`
+	Messages       []message       `json:"messages"`
+	Temperature    *float64        `json:"temperature,omitempty"`
+	TopP           *float64        `json:"top_p,omitempty"`
+	TopK           *int            `json:"top_k,omitempty"`
+	MaxTokens      *int            `json:"max_tokens,omitempty"`
+	Seed           *uint64         `json:"seed,omitempty"`
+	Stop           []string        `json:"stop,omitempty"`
+	Stream         bool            `json:"stream,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+type jsonSchema struct {
+	Name   string          `json:"name"`
+	Strict bool            `json:"strict"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message message `json:"message"`
+		Delta   struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+type session struct {
+	backend  *Backend
+	mu       sync.Mutex
+	messages []message
+}
+
+func (s *session) Respond(ctx context.Context, prompt string, opts fundament.GenerationOptions) (fundament.Response, error) {
+	text, err := s.complete(ctx, prompt, opts, nil)
+	if err != nil {
+		return fundament.Response{}, err
+	}
+	return fundament.Response{Text: text}, nil
+}
+
+func (s *session) RespondStructured(ctx context.Context, prompt string, schema fundament.Schema, opts fundament.GenerationOptions) (fundament.StructuredResponse, error) {
+	translated, err := schema.JSONSchema()
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	format := &responseFormat{
+		Type: "json_schema",
+		JSONSchema: &jsonSchema{
+			Name:   schemaName(schema.Name()),
+			Strict: true,
+			Schema: translated,
+		},
+	}
+	text, err := s.complete(ctx, prompt, opts, format)
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	return fundament.StructuredResponse{JSON: json.RawMessage(text)}, nil
+}
+
+func (s *session) complete(ctx context.Context, prompt string, opts fundament.GenerationOptions, format *responseFormat) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req := s.request(prompt, opts)
+	req.ResponseFormat = format
+	resp, err := s.backend.post(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var decoded chatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return "", fmt.Errorf("openai: decode response: %w", err)
+	}
+	if len(decoded.Choices) == 0 {
+		return "", errors.New("openai: response contained no choices")
+	}
+	text := decoded.Choices[0].Message.Content
+	s.commit(prompt, text)
+	return text, nil
+}
+
+func (s *session) RespondStream(ctx context.Context, prompt string, opts fundament.GenerationOptions, fn func(fundament.StreamChunk)) error {
+	if fn == nil {
+		return errors.New("openai: stream callback must not be nil")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req := s.request(prompt, opts)
+	req.Stream = true
+	resp, err := s.backend.post(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Hold back one chunk so the last one can be flagged final once the stream ends.
+	var (
+		full    strings.Builder
+		pending string
+		started bool
+		done    bool
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			done = true
+			break
+		}
+		var event chatResponse
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("openai: decode stream event: %w", err)
+		}
+		if len(event.Choices) == 0 {
+			continue
+		}
+		if event.Choices[0].FinishReason != "" {
+			done = true
+		}
+		if event.Choices[0].Delta.Content == "" {
+			continue
+		}
+		if started {
+			fn(fundament.StreamChunk{Text: pending})
+		}
+		pending = event.Choices[0].Delta.Content
+		started = true
+		full.WriteString(pending)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("openai: read stream: %w", err)
+	}
+	// A dropped connection ends the body without [DONE] or a finish_reason; the reply is partial.
+	if !done {
+		return errors.New("openai: stream ended before the final event")
+	}
+	fn(fundament.StreamChunk{Text: pending, Final: true})
+	s.commit(prompt, full.String())
+	return nil
+}
+
+func (s *session) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = nil
+	return nil
+}
+
+// request builds a completion request for the history plus prompt. Callers hold s.mu.
+func (s *session) request(prompt string, opts fundament.GenerationOptions) chatRequest {
+	messages := make([]message, 0, len(s.messages)+1)
+	messages = append(messages, s.messages...)
+	messages = append(messages, message{Role: "user", Content: prompt})
+	opts = opts.Flatten()
+	return chatRequest{
+		Model:       s.backend.cfg.Model,
+		Messages:    messages,
+		Temperature: opts.Temperature,
+		TopP:        opts.TopP,
+		TopK:        opts.TopK,
+		MaxTokens:   opts.MaxTokens,
+		Seed:        opts.Seed,
+		Stop:        opts.StopSequences,
+	}
+}
+
+// commit appends a completed turn to the history. Callers hold s.mu.
+func (s *session) commit(prompt, reply string) {
+	s.messages = append(s.messages,
+		message{Role: "user", Content: prompt},
+		message{Role: "assistant", Content: reply},
+	)
+}
+
+func (b *Backend) post(ctx context.Context, body chatRequest) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.BaseURL+"/chat/completions", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if body.Stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	b.authorize(req)
+	resp, err := b.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		return nil, decodeAPIError(resp)
+	}
+	return resp, nil
+}
+
+func decodeAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &payload) == nil && payload.Error.Message != "" {
+		message = payload.Error.Message
+	}
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// schemaName derives a response_format name, which must match ^[a-zA-Z0-9_-]{1,64}$.
+func schemaName(name string) string {
+	name = invalidNameChars.ReplaceAllString(name, "_")
+	if name == "" {
+		return "response"
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,433 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// fakeServer records chat requests and replies with the handler supplied per test.
+type fakeServer struct {
+	mu       sync.Mutex
+	requests []chatRequest
+	reply    func(w http.ResponseWriter, req chatRequest)
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/models":
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"local-model"}]}`)
+	case "/v1/chat/completions":
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.requests = append(f.requests, req)
+		f.mu.Unlock()
+		f.reply(w, req)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeServer) last() chatRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[len(f.requests)-1]
+}
+
+func newTestSession(t *testing.T, fake *fakeServer, instructions string) *fundament.Session {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	session, err := fundament.NewSession(fundament.SessionOptions{
+		Instructions: instructions,
+		Backend:      New(Config{BaseURL: srv.URL + "/v1", Model: "local-model", APIKey: "secret"}),
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func replyText(w http.ResponseWriter, text string) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": text}}},
+	})
+}
+
+func TestRespondKeepsHistoryAndMapsOptions(t *testing.T) {
+	turn := 0
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		turn++
+		replyText(w, fmt.Sprintf("answer %d", turn))
+	}}
+	session := newTestSession(t, fake, "be brief")
+
+	resp, err := session.Respond(context.Background(), "first",
+		fundament.WithTemperature(0.2), fundament.WithTopP(0.9), fundament.WithMaxTokens(32), fundament.WithSeed(7),
+		fundament.WithStopSequences("\nUser:"))
+	if err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if resp.Text != "answer 1" {
+		t.Fatalf("unexpected response %q", resp.Text)
+	}
+	req := fake.last()
+	if req.Model != "local-model" {
+		t.Fatalf("unexpected model %q", req.Model)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 ||
+		req.TopP == nil || *req.TopP != 0.9 ||
+		req.MaxTokens == nil || *req.MaxTokens != 32 ||
+		req.Seed == nil || *req.Seed != 7 ||
+		len(req.Stop) != 1 || req.Stop[0] != "\nUser:" {
+		t.Fatalf("options not mapped: %+v", req)
+	}
+
+	if _, err := session.Respond(context.Background(), "second"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	req = fake.last()
+	want := []message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer 1"},
+		{Role: "user", Content: "second"},
+	}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), req.Messages)
+	}
+	for i := range want {
+		if req.Messages[i] != want[i] {
+			t.Fatalf("message %d = %+v, want %+v", i, req.Messages[i], want[i])
+		}
+	}
+	if req.Temperature != nil {
+		t.Fatal("per-call options must not leak into later calls")
+	}
+}
+
+func TestRespondStructuredSendsJSONSchema(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		replyText(w, `{"status":"ok"}`)
+	}}
+	session := newTestSession(t, fake, "")
+
+	schema, err := fundament.SchemaFromRawJSON([]byte(`{"name":"Status Report","properties":[{"name":"status","schema":{"anyOf":["ok","fail"]}}]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	var out struct {
+		Status string `json:"status"`
+	}
+	if err := session.RespondStructuredInto(context.Background(), "report", schema, &out); err != nil {
+		t.Fatalf("RespondStructuredInto error: %v", err)
+	}
+	if out.Status != "ok" {
+		t.Fatalf("unexpected status %q", out.Status)
+	}
+
+	req := fake.last()
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" || req.ResponseFormat.JSONSchema == nil {
+		t.Fatalf("expected json_schema response format, got %+v", req.ResponseFormat)
+	}
+	if req.ResponseFormat.JSONSchema.Name != "Status_Report" {
+		t.Fatalf("unexpected schema name %q", req.ResponseFormat.JSONSchema.Name)
+	}
+	if !strings.Contains(string(req.ResponseFormat.JSONSchema.Schema), `"enum":["ok","fail"]`) {
+		t.Fatalf("schema not translated: %s", req.ResponseFormat.JSONSchema.Schema)
+	}
+	if len(req.Messages) != 1 {
+		t.Fatalf("expected no system message without instructions, got %+v", req.Messages)
+	}
+}
+
+func TestRespondStreamSSE(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			replyText(w, "history check")
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{"Hello", ", ", "world"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+			w.(http.Flusher).Flush()
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}}
+	session := newTestSession(t, fake, "")
+
+	ch, err := session.RespondStream(context.Background(), "greet")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var got []fundament.StreamChunk
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+		got = append(got, chunk)
+	}
+	if len(got) != 3 || got[0].Text != "Hello" || got[0].Final || got[2].Text != "world" || !got[2].Final {
+		t.Fatalf("unexpected chunks %+v", got)
+	}
+
+	if _, err := session.Respond(context.Background(), "again"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	req := fake.last()
+	if len(req.Messages) != 3 || req.Messages[1].Content != "Hello, world" {
+		t.Fatalf("streamed turn missing from history: %+v", req.Messages)
+	}
+}
+
+func TestRespondStreamTruncated(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			replyText(w, "ok")
+			return
+		}
+		// The connection drops mid-reply: no finish_reason and no [DONE].
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Once\"}}]}\n\n")
+	}}
+	session := newTestSession(t, fake, "")
+
+	ch, err := session.RespondStream(context.Background(), "story")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var last fundament.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	if last.Err == nil {
+		t.Fatalf("expected an error for a stream without a final event, got %+v", last)
+	}
+
+	if _, err := session.Respond(context.Background(), "again"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if req := fake.last(); len(req.Messages) != 1 {
+		t.Fatalf("truncated turn leaked into history: %+v", req.Messages)
+	}
+}
+
+func TestServerStopIsNotReportedAsFinishStop(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			json.NewEncoder(w).Encode(map[string]any{
+				"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": "Sure."}, "finish_reason": "stop"}},
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Sure.\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}}
+	session := newTestSession(t, fake, "")
+
+	// "stop" also ends every natural reply, so it cannot mean that a marker matched.
+	resp, err := session.Respond(context.Background(), "hi", fundament.WithStopSequences("\nUser:"))
+	if err != nil || resp.Text != "Sure." || resp.FinishReason != "" {
+		t.Fatalf("a natural end must not report a reason, got %+v, %v", resp, err)
+	}
+
+	ch, err := session.RespondStream(context.Background(), "hi", fundament.WithStopSequences("\nUser:"))
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var last fundament.StreamChunk
+	for chunk := range ch {
+		last = chunk
+	}
+	if !last.Final || last.FinishReason != "" {
+		t.Fatalf("a natural end must not report a reason on the final chunk, got %+v", last)
+	}
+}
+
+func TestSessionStopKeepsHistoryInSync(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if !req.Stream {
+			replyText(w, "Sure.\nUser: ignored")
+			return
+		}
+		// A server that does not honour stop sequences keeps streaming past the marker.
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, part := range []string{"Sure.", "\nUser:", " more"} {
+			fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", part)
+			w.(http.Flusher).Flush()
+		}
+		<-release
+	}}
+	session := newTestSession(t, fake, "")
+
+	ch, err := session.RespondStream(context.Background(), "streamed", fundament.WithStopSequences("\nUser:"))
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	for chunk := range ch {
+		if chunk.Err != nil {
+			t.Fatalf("stream error: %v", chunk.Err)
+		}
+	}
+	if _, err := session.Respond(context.Background(), "whole", fundament.WithStopSequences("\nUser:")); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "next"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	req := fake.last()
+	want := []string{"streamed", "Sure.", "whole", "Sure.", "next"}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("history out of sync with the transcript: %+v", req.Messages)
+	}
+	for i, content := range want {
+		if req.Messages[i].Content != content {
+			t.Fatalf("history out of sync with the transcript: %+v", req.Messages)
+		}
+	}
+}
+
+func TestRespondAPIErrorDoesNotCommitHistory(t *testing.T) {
+	fail := true
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error":{"message":"model crashed"}}`)
+			return
+		}
+		replyText(w, "ok")
+	}}
+	session := newTestSession(t, fake, "")
+
+	_, err := session.Respond(context.Background(), "lost")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Message != "model crashed" {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+
+	fail = false
+	if _, err := session.Respond(context.Background(), "kept"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if req := fake.last(); len(req.Messages) != 1 || req.Messages[0].Content != "kept" {
+		t.Fatalf("failed turn leaked into history: %+v", req.Messages)
+	}
+}
+
+func TestCheckAvailability(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{})
+	defer srv.Close()
+
+	got, err := New(Config{BaseURL: srv.URL + "/v1", Model: "local-model"}).CheckAvailability()
+	if err != nil || got.State != fundament.AvailabilityReady {
+		t.Fatalf("expected ready, got %+v, %v", got, err)
+	}
+	got, err = New(Config{BaseURL: srv.URL + "/v1", Model: "missing"}).CheckAvailability()
+	if err != nil || got.State != fundament.AvailabilityUnavailable || got.Reason != fundament.AvailabilityReasonModelNotReady {
+		t.Fatalf("expected model not ready, got %+v, %v", got, err)
+	}
+
+	loading := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "loading model", http.StatusServiceUnavailable)
+	}))
+	defer loading.Close()
+	got, err = New(Config{BaseURL: loading.URL + "/v1"}).CheckAvailability()
+	if err != nil || got.Reason != fundament.AvailabilityReasonModelNotReady {
+		t.Fatalf("expected model not ready while loading, got %+v, %v", got, err)
+	}
+}
+
+func TestCheckAvailabilityTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer stalled.Close()
+	defer close(release)
+
+	_, err := New(Config{BaseURL: stalled.URL + "/v1", AvailabilityTimeout: 20 * time.Millisecond}).CheckAvailability()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the check to time out, got %v", err)
+	}
+}
+
+func TestAPIErrorRetryable(t *testing.T) {
+	cases := map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+		http.StatusTooManyRequests:     true,
+		http.StatusInternalServerError: true,
+		http.StatusServiceUnavailable:  true,
+	}
+	for status, want := range cases {
+		err := &APIError{StatusCode: status}
+		if got := fundament.IsRetryable(err); got != want {
+			t.Fatalf("status %d: IsRetryable = %v, want %v", status, got, want)
+		}
+	}
+}
+
+func TestNewSessionFromTranscriptSeedsHistory(t *testing.T) {
+	fake := &fakeServer{reply: func(w http.ResponseWriter, req chatRequest) {
+		replyText(w, "Ada")
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	backend := New(Config{BaseURL: srv.URL + "/v1"})
+	transcript := &fundament.Transcript{Entries: []fundament.TranscriptEntry{
+		{Kind: fundament.EntryInstructions, Text: "be brief"},
+		{Kind: fundament.EntryPrompt, Text: "my name is Ada"},
+		{Kind: fundament.EntryStructuredResponse, JSON: json.RawMessage(`{"ok":true}`)},
+	}}
+	session, err := fundament.NewSession(fundament.SessionOptions{Backend: backend, Transcript: transcript})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	if _, err := session.Respond(context.Background(), "name?"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	want := []message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "my name is Ada"},
+		{Role: "assistant", Content: `{"ok":true}`},
+		{Role: "user", Content: "name?"},
+	}
+	req := fake.last()
+	if len(req.Messages) != len(want) {
+		t.Fatalf("expected %d messages, got %+v", len(want), req.Messages)
+	}
+	for i := range want {
+		if req.Messages[i] != want[i] {
+			t.Fatalf("message %d = %+v, want %+v", i, req.Messages[i], want[i])
+		}
+	}
+
+	tools := fundament.Transcript{Entries: []fundament.TranscriptEntry{{Kind: fundament.EntryToolCall, ToolName: "lookup"}}}
+	if _, err := backend.NewSessionFromTranscript(tools); err == nil {
+		t.Fatal("expected tool entries to be rejected")
+	}
+}
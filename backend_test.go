@@ -0,0 +1,255 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/domano/fundament/internal/native"
+)
+
+// nativeStub records what the stubbed shim was asked to do.
+type nativeStub struct {
+	api       *nativeAPI
+	mu        sync.Mutex
+	cancelled []native.CancelToken
+	destroyed []native.CancelToken
+	sessions  int
+}
+
+func (s *nativeStub) counts() (cancelled, destroyed, sessions int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cancelled), len(s.destroyed), s.sessions
+}
+
+func (s *nativeStub) backend() Backend {
+	return nativeBackend{api: s.api}
+}
+
+func newNativeStub(respond func(native.SessionRef, string, string, native.CancelToken) (string, string, error)) *nativeStub {
+	stub := &nativeStub{}
+	stub.api = &nativeAPI{
+		createSession: func(string) (native.SessionRef, error) {
+			return native.SessionRef(unsafe.Pointer(new(byte))), nil
+		},
+		destroySession: func(native.SessionRef) {
+			stub.mu.Lock()
+			stub.sessions++
+			stub.mu.Unlock()
+		},
+		respond: respond,
+		createToken: func() native.CancelToken {
+			return native.CancelToken(unsafe.Pointer(new(byte)))
+		},
+		cancelToken: func(tok native.CancelToken) {
+			stub.mu.Lock()
+			stub.cancelled = append(stub.cancelled, tok)
+			stub.mu.Unlock()
+		},
+		destroyToken: func(tok native.CancelToken) {
+			stub.mu.Lock()
+			stub.destroyed = append(stub.destroyed, tok)
+			stub.mu.Unlock()
+		},
+	}
+	return stub
+}
+
+func TestNativeRespondReturnsOnCancel(t *testing.T) {
+	release := make(chan struct{})
+	stub := newNativeStub(func(native.SessionRef, string, string, native.CancelToken) (string, string, error) {
+		<-release
+		return "late", "", nil
+	})
+	bs, err := stub.backend().NewSession("")
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := bs.Respond(ctx, "hi", GenerationOptions{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if c, d, _ := stub.counts(); c != 1 || d != 0 {
+		t.Fatalf("expected the token cancelled but not destroyed, got cancelled=%d destroyed=%d", c, d)
+	}
+
+	// Closing while the abandoned call unwinds must not free the handle underneath it.
+	bs.Close()
+	if _, _, s := stub.counts(); s != 0 {
+		t.Fatalf("session destroyed while a call was in flight")
+	}
+	close(release)
+	waitFor(t, func() bool {
+		_, d, s := stub.counts()
+		return d == 1 && s == 1
+	})
+}
+
+func TestNativeCallsWaitForAbandonedCall(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var (
+		mu     sync.Mutex
+		active int
+	)
+	stub := newNativeStub(func(_ native.SessionRef, prompt string, _ string, _ native.CancelToken) (string, string, error) {
+		mu.Lock()
+		active++
+		overlap := active > 1
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+		if overlap {
+			return "", "", errors.New("concurrent request")
+		}
+		if prompt == "slow" {
+			close(started)
+			<-release
+		}
+		return prompt, "", nil
+	})
+	bs, err := stub.backend().NewSession("")
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer bs.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := bs.Respond(ctx, "slow", GenerationOptions{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { close(release) })
+	resp, err := bs.Respond(context.Background(), "next", GenerationOptions{})
+	if err != nil {
+		t.Fatalf("respond: %v", err)
+	}
+	if resp.Text != "next" {
+		t.Fatalf("unexpected response %q", resp.Text)
+	}
+}
+
+func TestNativePrewarmWaitsForCallSlot(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	stub := newNativeStub(func(native.SessionRef, string, string, native.CancelToken) (string, string, error) {
+		close(started)
+		<-release
+		return "ok", "", nil
+	})
+	var prewarmed sync.WaitGroup
+	prewarmed.Add(1)
+	var overlapped bool
+	stub.api.prewarm = func(native.SessionRef, string) error {
+		defer prewarmed.Done()
+		select {
+		case <-release:
+		default:
+			overlapped = true
+		}
+		return nil
+	}
+	bs, err := stub.backend().NewSession("")
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer bs.Close()
+
+	go bs.Respond(context.Background(), "hi", GenerationOptions{})
+	<-started
+	go bs.(Prewarmer).Prewarm(context.Background(), "")
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	prewarmed.Wait()
+	if overlapped {
+		t.Fatal("prewarm ran while a request held the session")
+	}
+}
+
+func TestNativeStreamDropsLateChunks(t *testing.T) {
+	stub := newNativeStub(nil)
+	sent := make(chan struct{})
+	release := make(chan struct{})
+	stub.api.stream = func(_ native.SessionRef, _, _ string, _ native.CancelToken, cb native.StreamCallback) (string, error) {
+		cb("early", false)
+		close(sent)
+		<-release
+		cb("late", true)
+		return "", nil
+	}
+	bs, err := stub.backend().NewSession("")
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer bs.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	go func() {
+		<-sent
+		cancel()
+	}()
+	err = bs.RespondStream(ctx, "hi", GenerationOptions{}, func(c StreamChunk) {
+		got = append(got, c.Text)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected canceled, got %v", err)
+	}
+	close(release)
+	waitFor(t, func() bool {
+		_, d, _ := stub.counts()
+		return d == 1
+	})
+	if len(got) != 1 || got[0] != "early" {
+		t.Fatalf("expected only the early chunk, got %v", got)
+	}
+}
+
+func TestNativeWarningsReachResponses(t *testing.T) {
+	const meta = `{"warnings":["seed ignored without topK, topP, or a random sampling mode"]}`
+	stub := newNativeStub(func(native.SessionRef, string, string, native.CancelToken) (string, string, error) {
+		return "ok", meta, nil
+	})
+	stub.api.stream = func(_ native.SessionRef, _, _ string, _ native.CancelToken, cb native.StreamCallback) (string, error) {
+		cb("a", false)
+		cb("b", true)
+		return meta, nil
+	}
+	bs, err := stub.backend().NewSession("")
+	if err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	defer bs.Close()
+
+	resp, err := bs.Respond(context.Background(), "hi", GenerationOptions{})
+	if err != nil {
+		t.Fatalf("respond: %v", err)
+	}
+	if len(resp.Warnings) != 1 || !strings.HasPrefix(resp.Warnings[0], "seed ignored") {
+		t.Fatalf("unexpected warnings %q", resp.Warnings)
+	}
+
+	var chunks []StreamChunk
+	if err := bs.RespondStream(context.Background(), "hi", GenerationOptions{}, func(c StreamChunk) {
+		chunks = append(chunks, c)
+	}); err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0].Warnings != nil || !chunks[1].Final || chunks[1].Text != "b" || len(chunks[1].Warnings) != 1 {
+		t.Fatalf("warnings must arrive on the final chunk, got %+v", chunks)
+	}
+}
@@ -0,0 +1,192 @@
+// Package cassette records fundament.Backend interactions to a file and replays them
+// deterministically, so conversations captured once on a Mac can drive tests on Linux CI.
+//
+//	rec := cassette.NewRecorder(fundament.NativeBackend())
+//	// ... run the code under test with SessionOptions{Backend: rec} ...
+//	err := rec.Save("testdata/travel.json")
+//
+//	player, err := cassette.Load("testdata/travel.json", cassette.ReplayOptions{IgnoreSeed: true})
+//	// ... run the same code with SessionOptions{Backend: player} ...
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// Version is the cassette file format version written by Recorder.
+const Version = 1
+
+// Kind identifies the session method of an Interaction.
+type Kind string
+
+const (
+	KindRespond           Kind = "respond"
+	KindRespondStructured Kind = "respondStructured"
+	KindRespondStream     Kind = "respondStream"
+)
+
+// Cassette is the serialised form of a recording.
+type Cassette struct {
+	Version      int                     `json:"version"`
+	Availability *fundament.Availability `json:"availability,omitempty"`
+	Interactions []Interaction           `json:"interactions"`
+}
+
+// Interaction captures a single request and what the backend returned.
+type Interaction struct {
+	Kind         Kind   `json:"kind"`
+	Instructions string `json:"instructions,omitempty"`
+	Prompt       string `json:"prompt"`
+	// Options is the encoded options blob, see fundament.GenerationOptions.Encode.
+	Options  string          `json:"options,omitempty"`
+	Schema   json.RawMessage `json:"schema,omitempty"`
+	Response string          `json:"response,omitempty"`
+	Chunks   []Chunk         `json:"chunks,omitempty"`
+	// Warnings and FinishReason are those of the Response; streams keep them on the final Chunk.
+	Warnings     []string               `json:"warnings,omitempty"`
+	FinishReason fundament.FinishReason `json:"finishReason,omitempty"`
+	Error        string                 `json:"error,omitempty"`
+	// ErrorDetail keeps the type of Error when replay can reproduce it, see ErrorDetail.
+	ErrorDetail *ErrorDetail `json:"errorDetail,omitempty"`
+	// Duration is the wall time of the whole call.
+	Duration Millis `json:"durationMs"`
+}
+
+// Chunk is one streamed update together with its offset from the start of the call.
+type Chunk struct {
+	Text         string                 `json:"text"`
+	Final        bool                   `json:"final,omitempty"`
+	Warnings     []string               `json:"warnings,omitempty"`
+	FinishReason fundament.FinishReason `json:"finishReason,omitempty"`
+	Offset       Millis                 `json:"offsetMs"`
+}
+
+// ErrorDetail records a typed error so that replay returns one that errors.Is and errors.As
+// treat like the original: *fundament.InvalidOptionError, *fundament.SchemaViolationError,
+// context.Canceled, and context.DeadlineExceeded. Other errors, such as the APIError of an
+// HTTP backend, replay as plain errors with the recorded message.
+type ErrorDetail struct {
+	// Type is "invalidOption", "schemaViolation", "canceled", or "deadlineExceeded".
+	Type       string          `json:"type"`
+	Option     string          `json:"option,omitempty"`
+	Value      any             `json:"value,omitempty"`
+	Constraint string          `json:"constraint,omitempty"`
+	Violations []Violation     `json:"violations,omitempty"`
+	JSON       json.RawMessage `json:"json,omitempty"`
+}
+
+// Violation is a recorded fundament.SchemaViolation.
+type Violation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// errorDetail describes err for recording, or returns nil for errors replayed by message only.
+func errorDetail(err error) *ErrorDetail {
+	var invalid *fundament.InvalidOptionError
+	var violation *fundament.SchemaViolationError
+	switch {
+	case errors.As(err, &invalid):
+		return &ErrorDetail{Type: "invalidOption", Option: invalid.Option, Value: invalid.Value, Constraint: invalid.Constraint}
+	case errors.As(err, &violation):
+		d := &ErrorDetail{Type: "schemaViolation", JSON: violation.JSON}
+		for _, v := range violation.Violations {
+			d.Violations = append(d.Violations, Violation{Pointer: v.Pointer, Message: v.Message})
+		}
+		return d
+	case errors.Is(err, context.Canceled):
+		return &ErrorDetail{Type: "canceled"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return &ErrorDetail{Type: "deadlineExceeded"}
+	}
+	return nil
+}
+
+// err rebuilds the recorded error, or returns nil if the call succeeded.
+func (in Interaction) err() error {
+	if in.Error == "" {
+		return nil
+	}
+	var cause error
+	if d := in.ErrorDetail; d != nil {
+		switch d.Type {
+		case "invalidOption":
+			cause = &fundament.InvalidOptionError{Option: d.Option, Value: d.Value, Constraint: d.Constraint}
+		case "schemaViolation":
+			violation := &fundament.SchemaViolationError{JSON: d.JSON}
+			for _, v := range d.Violations {
+				violation.Violations = append(violation.Violations, fundament.SchemaViolation{Pointer: v.Pointer, Message: v.Message})
+			}
+			cause = violation
+		case "canceled":
+			cause = context.Canceled
+		case "deadlineExceeded":
+			cause = context.DeadlineExceeded
+		}
+	}
+	if cause == nil {
+		return errors.New(in.Error)
+	}
+	return &replayedError{msg: in.Error, cause: cause}
+}
+
+// replayedError keeps the recorded message while unwrapping to the rebuilt typed error.
+type replayedError struct {
+	msg   string
+	cause error
+}
+
+func (e *replayedError) Error() string { return e.msg }
+func (e *replayedError) Unwrap() error { return e.cause }
+
+// Millis is a time.Duration serialised as fractional milliseconds.
+type Millis time.Duration
+
+func (m Millis) MarshalJSON() ([]byte, error) {
+	return json.Marshal(float64(m) / float64(time.Millisecond))
+}
+
+func (m *Millis) UnmarshalJSON(data []byte) error {
+	var ms float64
+	if err := json.Unmarshal(data, &ms); err != nil {
+		return err
+	}
+	*m = Millis(ms * float64(time.Millisecond))
+	return nil
+}
+
+// ReadFile decodes a cassette from path.
+func ReadFile(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("cassette: decode %s: %w", path, err)
+	}
+	if c.Version != Version {
+		return nil, fmt.Errorf("cassette: %s has unsupported version %d", path, c.Version)
+	}
+	return &c, nil
+}
+
+// WriteFile encodes c to path, creating parent directories as needed.
+func (c *Cassette) WriteFile(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,328 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+const travelSchema = `{"name":"Trip","properties":[{"name":"city","schema":{"type":"string"}}]}`
+
+// record drives a scripted model through a recorder and returns the saved cassette path.
+func record(t *testing.T) string {
+	t.Helper()
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Exact("hello")).Reply("hi there")
+	model.On(fundamenttest.Exact("plan")).ReplyJSON(`{"city":"Kyoto"}`)
+	model.On(fundamenttest.Exact("count")).Stream("one ", "two ", "three").Delay(20 * time.Millisecond)
+	model.On(fundamenttest.Exact("explode")).Fail(errors.New("model overloaded"))
+
+	rec := NewRecorder(model)
+	if _, err := rec.CheckAvailability(); err != nil {
+		t.Fatalf("CheckAvailability error: %v", err)
+	}
+	session, err := fundament.NewSession(fundament.SessionOptions{Instructions: "be  brief", Backend: rec})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	ctx := context.Background()
+	if _, err := session.Respond(ctx, "hello", fundament.WithSeed(1), fundament.WithTemperature(0.5)); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	schema, err := fundament.SchemaFromRawJSON([]byte(travelSchema))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if _, err := session.RespondStructured(ctx, "plan", schema); err != nil {
+		t.Fatalf("RespondStructured error: %v", err)
+	}
+	stream, err := session.RespondStream(ctx, "count")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	for range stream {
+	}
+	if _, err := session.Respond(ctx, "explode"); err == nil {
+		t.Fatal("expected injected error")
+	}
+
+	path := filepath.Join(t.TempDir(), "cassettes", "session.json")
+	if err := rec.Save(path); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	return path
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	path := record(t)
+
+	c, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile error: %v", err)
+	}
+	if len(c.Interactions) != 4 {
+		t.Fatalf("expected 4 interactions, got %d", len(c.Interactions))
+	}
+	if c.Interactions[0].Options != `{"seed":1,"temperature":0.5,"v":1}` {
+		t.Fatalf("unexpected options blob %q", c.Interactions[0].Options)
+	}
+	if c.Availability == nil || c.Availability.State != fundament.AvailabilityReady {
+		t.Fatalf("expected recorded availability, got %+v", c.Availability)
+	}
+
+	player := NewReplayer(c, ReplayOptions{})
+	session, err := fundament.NewSession(fundament.SessionOptions{Instructions: "be  brief", Backend: player})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	ctx := context.Background()
+
+	resp, err := session.Respond(ctx, "hello", fundament.WithTemperature(0.5), fundament.WithSeed(1))
+	if err != nil || resp.Text != "hi there" {
+		t.Fatalf("unexpected replay %q, %v", resp.Text, err)
+	}
+
+	schema, _ := fundament.SchemaFromRawJSON([]byte(travelSchema))
+	structured, err := session.RespondStructured(ctx, "plan", schema)
+	if err != nil || string(structured.JSON) != `{"city":"Kyoto"}` {
+		t.Fatalf("unexpected structured replay %s, %v", structured.JSON, err)
+	}
+
+	stream, err := session.RespondStream(ctx, "count")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var chunks []fundament.StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 3 || chunks[0].Text != "one " || chunks[2].Text != "three" || !chunks[2].Final {
+		t.Fatalf("chunk boundaries not reproduced: %+v", chunks)
+	}
+
+	if _, err := session.Respond(ctx, "explode"); err == nil || err.Error() != "model overloaded" {
+		t.Fatalf("expected recorded error, got %v", err)
+	}
+
+	if unused := player.Unused(); len(unused) != 0 {
+		t.Fatalf("expected every interaction to be replayed, %d left", len(unused))
+	}
+	if _, err := session.Respond(ctx, "hello", fundament.WithTemperature(0.5), fundament.WithSeed(1)); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("interactions must replay once, got %v", err)
+	}
+}
+
+func TestReplayUnmatched(t *testing.T) {
+	player, err := Load(record(t), ReplayOptions{})
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	session, _ := fundament.NewSession(fundament.SessionOptions{Instructions: "be brief", Backend: player})
+	defer session.Close()
+
+	// Whitespace in the instructions and a different seed both count as a mismatch by default.
+	if _, err := session.Respond(context.Background(), "hello", fundament.WithTemperature(0.5), fundament.WithSeed(1)); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("expected ErrNoMatch, got %v", err)
+	}
+	if len(player.Unused()) != 4 {
+		t.Fatal("failed lookups must not consume interactions")
+	}
+}
+
+func TestReplayMatchingRules(t *testing.T) {
+	player, err := Load(record(t), ReplayOptions{IgnoreSeed: true, NormalizeWhitespace: true})
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	session, _ := fundament.NewSession(fundament.SessionOptions{Instructions: "be brief", Backend: player})
+	defer session.Close()
+
+	resp, err := session.Respond(context.Background(), "  hello\n", fundament.WithTemperature(0.5), fundament.WithSeed(42))
+	if err != nil || resp.Text != "hi there" {
+		t.Fatalf("expected relaxed match, got %q, %v", resp.Text, err)
+	}
+	if _, err := session.Respond(context.Background(), "hello", fundament.WithTemperature(0.9)); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("other options must still match exactly, got %v", err)
+	}
+}
+
+func TestReplayRealTime(t *testing.T) {
+	path := record(t)
+	for _, realTime := range []bool{false, true} {
+		player, err := Load(path, ReplayOptions{RealTime: realTime})
+		if err != nil {
+			t.Fatalf("Load error: %v", err)
+		}
+		session, _ := fundament.NewSession(fundament.SessionOptions{Instructions: "be  brief", Backend: player})
+		start := time.Now()
+		stream, err := session.RespondStream(context.Background(), "count")
+		if err != nil {
+			t.Fatalf("RespondStream error: %v", err)
+		}
+		for range stream {
+		}
+		elapsed := time.Since(start)
+		session.Close()
+
+		// Recording waited 20ms before each of the three chunks.
+		if realTime && elapsed < 50*time.Millisecond {
+			t.Fatalf("real-time replay finished too quickly: %v", elapsed)
+		}
+		if !realTime && elapsed > 40*time.Millisecond {
+			t.Fatalf("instant replay took %v", elapsed)
+		}
+	}
+}
+
+func TestForkUnderRecordAndReplay(t *testing.T) {
+	run := func(backend fundament.Backend) string {
+		t.Helper()
+		session, err := fundament.NewSession(fundament.SessionOptions{Instructions: "be brief", Backend: backend})
+		if err != nil {
+			t.Fatalf("NewSession error: %v", err)
+		}
+		defer session.Close()
+		if _, err := session.Respond(context.Background(), "hello"); err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+		fork, err := session.Fork(3)
+		if err != nil {
+			t.Fatalf("Fork error: %v", err)
+		}
+		defer fork.Close()
+		resp, err := fork.Respond(context.Background(), "and then?")
+		if err != nil {
+			t.Fatalf("fork Respond error: %v", err)
+		}
+		return resp.Text
+	}
+
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Exact("hello")).Reply("hi there")
+	model.On(fundamenttest.Any()).Reply("then nothing")
+	rec := NewRecorder(model)
+	run(rec)
+	if c := rec.Cassette(); len(c.Interactions) != 2 || c.Interactions[1].Instructions != "be brief" {
+		t.Fatalf("fork interactions not recorded: %+v", c.Interactions)
+	}
+
+	player := NewReplayer(rec.Cassette(), ReplayOptions{})
+	if got := run(player); got != "then nothing" {
+		t.Fatalf("replayed fork answered %q", got)
+	}
+	if len(player.Unused()) != 0 {
+		t.Fatalf("unused interactions %+v", player.Unused())
+	}
+}
+
+// metadataBackend answers every call with warnings and a finish reason, or with err.
+type metadataBackend struct {
+	err error
+}
+
+func (b metadataBackend) NewSession(string) (fundament.BackendSession, error) { return b, nil }
+
+func (b metadataBackend) CheckAvailability() (fundament.Availability, error) {
+	return fundament.Availability{State: fundament.AvailabilityReady}, nil
+}
+
+func (b metadataBackend) Respond(context.Context, string, fundament.GenerationOptions) (fundament.Response, error) {
+	if b.err != nil {
+		return fundament.Response{}, b.err
+	}
+	return fundament.Response{Text: "hi", Warnings: []string{"topK ignored"}, FinishReason: fundament.FinishLength}, nil
+}
+
+func (b metadataBackend) RespondStructured(context.Context, string, fundament.Schema, fundament.GenerationOptions) (fundament.StructuredResponse, error) {
+	if b.err != nil {
+		return fundament.StructuredResponse{}, b.err
+	}
+	return fundament.StructuredResponse{JSON: []byte(`{"city":"Kyoto"}`), Warnings: []string{"seed ignored"}}, nil
+}
+
+func (b metadataBackend) RespondStream(_ context.Context, _ string, _ fundament.GenerationOptions, fn func(fundament.StreamChunk)) error {
+	if b.err != nil {
+		return b.err
+	}
+	fn(fundament.StreamChunk{Text: "hi", Final: true, Warnings: []string{"topK ignored"}, FinishReason: fundament.FinishLength})
+	return nil
+}
+
+func (b metadataBackend) Close() error { return nil }
+
+func TestReplayRestoresMetadataAndTypedErrors(t *testing.T) {
+	invalid := &fundament.InvalidOptionError{Option: "topK", Value: 50, Constraint: "at most 40"}
+	violation := &fundament.SchemaViolationError{
+		Violations: []fundament.SchemaViolation{{Pointer: "/city", Message: "expected string, got number"}},
+		JSON:       []byte(`{"city":1}`),
+	}
+	schema, _ := fundament.SchemaFromRawJSON([]byte(travelSchema))
+
+	// replay records one call against backend and replays it, returning both sessions' results.
+	replay := func(backend fundament.Backend, call func(*fundament.Session) (any, error)) (any, error) {
+		t.Helper()
+		rec := NewRecorder(backend)
+		live, _ := fundament.NewSession(fundament.SessionOptions{Backend: rec})
+		call(live)
+		live.Close()
+		path := filepath.Join(t.TempDir(), "cassette.json")
+		if err := rec.Save(path); err != nil {
+			t.Fatalf("Save error: %v", err)
+		}
+		player, err := Load(path, ReplayOptions{})
+		if err != nil {
+			t.Fatalf("Load error: %v", err)
+		}
+		replayed, _ := fundament.NewSession(fundament.SessionOptions{Backend: player})
+		defer replayed.Close()
+		return call(replayed)
+	}
+	respond := func(s *fundament.Session) (any, error) { return s.Respond(context.Background(), "hi") }
+	structured := func(s *fundament.Session) (any, error) {
+		return s.RespondStructured(context.Background(), "plan", schema)
+	}
+	stream := func(s *fundament.Session) (any, error) {
+		ch, err := s.RespondStream(context.Background(), "hi")
+		if err != nil {
+			return nil, err
+		}
+		var last fundament.StreamChunk
+		for chunk := range ch {
+			last = chunk
+		}
+		return last, last.Err
+	}
+
+	got, err := replay(metadataBackend{}, respond)
+	if resp := got.(fundament.Response); err != nil || resp.FinishReason != fundament.FinishLength || len(resp.Warnings) != 1 {
+		t.Fatalf("response metadata not replayed: %+v, %v", resp, err)
+	}
+	got, err = replay(metadataBackend{}, structured)
+	if resp := got.(fundament.StructuredResponse); err != nil || len(resp.Warnings) != 1 {
+		t.Fatalf("structured warnings not replayed: %+v, %v", resp, err)
+	}
+	got, err = replay(metadataBackend{}, stream)
+	if last := got.(fundament.StreamChunk); err != nil || last.FinishReason != fundament.FinishLength || len(last.Warnings) != 1 {
+		t.Fatalf("final chunk metadata not replayed: %+v, %v", last, err)
+	}
+
+	var gotInvalid *fundament.InvalidOptionError
+	if _, err := replay(metadataBackend{err: invalid}, respond); !errors.As(err, &gotInvalid) || gotInvalid.Option != "topK" || err.Error() != invalid.Error() {
+		t.Fatalf("expected a replayed *InvalidOptionError, got %v", err)
+	}
+	var gotViolation *fundament.SchemaViolationError
+	if _, err := replay(metadataBackend{err: violation}, structured); !errors.As(err, &gotViolation) || gotViolation.Violations[0] != violation.Violations[0] {
+		t.Fatalf("expected a replayed *SchemaViolationError, got %v", err)
+	}
+	if _, err := replay(metadataBackend{err: context.DeadlineExceeded}, stream); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a replayed deadline error, got %v", err)
+	}
+}
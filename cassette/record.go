@@ -0,0 +1,166 @@
+package cassette
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// Recorder wraps a Backend and captures every interaction that passes through it.
+type Recorder struct {
+	backend fundament.Backend
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder returns a Recorder that forwards calls to backend.
+func NewRecorder(backend fundament.Backend) *Recorder {
+	return &Recorder{
+		backend:  backend,
+		cassette: Cassette{Version: Version},
+	}
+}
+
+// Cassette returns a copy of everything recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.cassette
+	c.Interactions = append([]Interaction(nil), r.cassette.Interactions...)
+	return &c
+}
+
+// Save writes the recording to path.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().WriteFile(path)
+}
+
+// NewSession implements fundament.Backend.
+func (r *Recorder) NewSession(instructions string) (fundament.BackendSession, error) {
+	inner, err := r.backend.NewSession(instructions)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingSession{recorder: r, inner: inner, instructions: instructions}, nil
+}
+
+// NewSessionFromTranscript implements fundament.TranscriptBackend when the wrapped backend
+// does. Interactions of the restored session are recorded under the transcript's instructions.
+func (r *Recorder) NewSessionFromTranscript(t fundament.Transcript) (fundament.BackendSession, error) {
+	tb, ok := r.backend.(fundament.TranscriptBackend)
+	if !ok {
+		return nil, errors.New("cassette: recorded backend cannot restore a transcript")
+	}
+	inner, err := tb.NewSessionFromTranscript(t)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingSession{recorder: r, inner: inner, instructions: t.Instructions()}, nil
+}
+
+// CheckAvailability implements fundament.Backend and records the result.
+func (r *Recorder) CheckAvailability() (fundament.Availability, error) {
+	a, err := r.backend.CheckAvailability()
+	if err == nil {
+		r.mu.Lock()
+		r.cassette.Availability = &a
+		r.mu.Unlock()
+	}
+	return a, err
+}
+
+func (r *Recorder) add(in Interaction) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+}
+
+type recordingSession struct {
+	recorder     *Recorder
+	inner        fundament.BackendSession
+	instructions string
+}
+
+func (s *recordingSession) interaction(kind Kind, prompt string, opts fundament.GenerationOptions) (Interaction, error) {
+	blob, err := opts.Encode()
+	if err != nil {
+		return Interaction{}, err
+	}
+	return Interaction{Kind: kind, Instructions: s.instructions, Prompt: prompt, Options: blob}, nil
+}
+
+func (s *recordingSession) Respond(ctx context.Context, prompt string, opts fundament.GenerationOptions) (fundament.Response, error) {
+	in, err := s.interaction(KindRespond, prompt, opts)
+	if err != nil {
+		return fundament.Response{}, err
+	}
+	start := time.Now()
+	resp, err := s.inner.Respond(ctx, prompt, opts)
+	in.Duration = Millis(time.Since(start))
+	if err != nil {
+		in.Error, in.ErrorDetail = err.Error(), errorDetail(err)
+	} else {
+		in.Response, in.Warnings, in.FinishReason = resp.Text, resp.Warnings, resp.FinishReason
+	}
+	s.recorder.add(in)
+	return resp, err
+}
+
+func (s *recordingSession) RespondStructured(ctx context.Context, prompt string, schema fundament.Schema, opts fundament.GenerationOptions) (fundament.StructuredResponse, error) {
+	in, err := s.interaction(KindRespondStructured, prompt, opts)
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	in.Schema = json.RawMessage(schema.Raw())
+	start := time.Now()
+	resp, err := s.inner.RespondStructured(ctx, prompt, schema, opts)
+	in.Duration = Millis(time.Since(start))
+	if err != nil {
+		in.Error, in.ErrorDetail = err.Error(), errorDetail(err)
+	} else {
+		in.Response, in.Warnings = string(resp.JSON), resp.Warnings
+	}
+	s.recorder.add(in)
+	return resp, err
+}
+
+func (s *recordingSession) RespondStream(ctx context.Context, prompt string, opts fundament.GenerationOptions, fn func(fundament.StreamChunk)) error {
+	in, err := s.interaction(KindRespondStream, prompt, opts)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	err = s.inner.RespondStream(ctx, prompt, opts, func(chunk fundament.StreamChunk) {
+		in.Chunks = append(in.Chunks, Chunk{
+			Text:         chunk.Text,
+			Final:        chunk.Final,
+			Warnings:     chunk.Warnings,
+			FinishReason: chunk.FinishReason,
+			Offset:       Millis(time.Since(start)),
+		})
+		fn(chunk)
+	})
+	in.Duration = Millis(time.Since(start))
+	if err != nil {
+		in.Error, in.ErrorDetail = err.Error(), errorDetail(err)
+	}
+	s.recorder.add(in)
+	return err
+}
+
+// Prewarm forwards to the wrapped session; prewarming is not recorded.
+func (s *recordingSession) Prewarm(ctx context.Context, promptPrefix string) error {
+	if p, ok := s.inner.(fundament.Prewarmer); ok {
+		return p.Prewarm(ctx, promptPrefix)
+	}
+	return nil
+}
+
+func (s *recordingSession) Close() error {
+	return s.inner.Close()
+}
@@ -0,0 +1,248 @@
+package cassette
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// ErrNoMatch is wrapped by errors returned for requests that are not on the cassette.
+var ErrNoMatch = errors.New("cassette: no matching interaction")
+
+// ReplayOptions configure how requests are matched against recorded interactions.
+type ReplayOptions struct {
+	// IgnoreSeed matches interactions regardless of the sampling seed.
+	IgnoreSeed bool
+	// NormalizeWhitespace collapses runs of whitespace in prompts and instructions before comparing.
+	NormalizeWhitespace bool
+	// RealTime reproduces the recorded latency of calls and the spacing between streamed chunks.
+	RealTime bool
+}
+
+// Replayer is a fundament.Backend that serves interactions from a Cassette.
+// Each interaction is used at most once; requests are matched against the
+// first unused interaction with the same kind, instructions, prompt, options, and schema.
+type Replayer struct {
+	opts ReplayOptions
+
+	mu           sync.Mutex
+	availability *fundament.Availability
+	interactions []Interaction
+	used         []bool
+}
+
+// Load reads the cassette at path and returns a Replayer for it.
+func Load(path string, opts ReplayOptions) (*Replayer, error) {
+	c, err := ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(c, opts), nil
+}
+
+// NewReplayer returns a Replayer serving the interactions in c.
+func NewReplayer(c *Cassette, opts ReplayOptions) *Replayer {
+	return &Replayer{
+		opts:         opts,
+		availability: c.Availability,
+		interactions: append([]Interaction(nil), c.Interactions...),
+		used:         make([]bool, len(c.Interactions)),
+	}
+}
+
+// Unused returns the interactions that were never replayed, which usually means the
+// code under test changed since the cassette was recorded.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []Interaction
+	for i, in := range r.interactions {
+		if !r.used[i] {
+			out = append(out, in)
+		}
+	}
+	return out
+}
+
+// NewSession implements fundament.Backend.
+func (r *Replayer) NewSession(instructions string) (fundament.BackendSession, error) {
+	return &replaySession{replayer: r, instructions: instructions}, nil
+}
+
+// NewSessionFromTranscript implements fundament.TranscriptBackend. Interactions are matched
+// as for NewSession with the transcript's instructions; the earlier turns are not compared.
+func (r *Replayer) NewSessionFromTranscript(t fundament.Transcript) (fundament.BackendSession, error) {
+	return r.NewSession(t.Instructions())
+}
+
+// CheckAvailability implements fundament.Backend, reporting the recorded availability or ready.
+func (r *Replayer) CheckAvailability() (fundament.Availability, error) {
+	if r.availability != nil {
+		return *r.availability, nil
+	}
+	return fundament.Availability{State: fundament.AvailabilityReady, Reason: fundament.AvailabilityReasonNone}, nil
+}
+
+func (r *Replayer) take(want Interaction) (Interaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, err := r.key(want)
+	if err != nil {
+		return Interaction{}, err
+	}
+	for i, in := range r.interactions {
+		if r.used[i] {
+			continue
+		}
+		candidate, err := r.key(in)
+		if err != nil {
+			return Interaction{}, err
+		}
+		if candidate == key {
+			r.used[i] = true
+			return in, nil
+		}
+	}
+	return Interaction{}, fmt.Errorf("%w for %s prompt %q (instructions %q, options %q)", ErrNoMatch, want.Kind, want.Prompt, want.Instructions, want.Options)
+}
+
+type matchKey struct {
+	kind         Kind
+	instructions string
+	prompt       string
+	options      string
+	schema       string
+}
+
+func (r *Replayer) key(in Interaction) (matchKey, error) {
+	k := matchKey{
+		kind:         in.Kind,
+		instructions: in.Instructions,
+		prompt:       in.Prompt,
+		options:      in.Options,
+	}
+	if r.opts.NormalizeWhitespace {
+		k.instructions = strings.Join(strings.Fields(k.instructions), " ")
+		k.prompt = strings.Join(strings.Fields(k.prompt), " ")
+	}
+	if r.opts.IgnoreSeed && k.options != "" {
+		opts, err := fundament.DecodeGenerationOptions(k.options)
+		if err != nil {
+			return matchKey{}, fmt.Errorf("cassette: decode options %q: %w", k.options, err)
+		}
+		opts.Seed = nil
+		if opts.Sampling != nil {
+			sampling := *opts.Sampling
+			sampling.Seed = nil
+			opts.Sampling = &sampling
+		}
+		if k.options, err = opts.Encode(); err != nil {
+			return matchKey{}, err
+		}
+	}
+	if len(in.Schema) > 0 {
+		// Compact so indentation differences in cassette files do not matter.
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, in.Schema); err != nil {
+			return matchKey{}, fmt.Errorf("cassette: decode schema: %w", err)
+		}
+		k.schema = buf.String()
+	}
+	return k, nil
+}
+
+type replaySession struct {
+	replayer     *Replayer
+	instructions string
+}
+
+func (s *replaySession) find(kind Kind, prompt string, opts fundament.GenerationOptions, schema fundament.Schema) (Interaction, error) {
+	blob, err := opts.Encode()
+	if err != nil {
+		return Interaction{}, err
+	}
+	return s.replayer.take(Interaction{
+		Kind:         kind,
+		Instructions: s.instructions,
+		Prompt:       prompt,
+		Options:      blob,
+		Schema:       json.RawMessage(schema.Raw()),
+	})
+}
+
+func (s *replaySession) Respond(ctx context.Context, prompt string, opts fundament.GenerationOptions) (fundament.Response, error) {
+	in, err := s.find(KindRespond, prompt, opts, fundament.Schema{})
+	if err != nil {
+		return fundament.Response{}, err
+	}
+	if err := s.sleep(ctx, time.Duration(in.Duration)); err != nil {
+		return fundament.Response{}, err
+	}
+	if err := in.err(); err != nil {
+		return fundament.Response{}, err
+	}
+	return fundament.Response{Text: in.Response, Warnings: in.Warnings, FinishReason: in.FinishReason}, nil
+}
+
+func (s *replaySession) RespondStructured(ctx context.Context, prompt string, schema fundament.Schema, opts fundament.GenerationOptions) (fundament.StructuredResponse, error) {
+	in, err := s.find(KindRespondStructured, prompt, opts, schema)
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	if err := s.sleep(ctx, time.Duration(in.Duration)); err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	if err := in.err(); err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	return fundament.StructuredResponse{JSON: json.RawMessage(in.Response), Warnings: in.Warnings}, nil
+}
+
+func (s *replaySession) RespondStream(ctx context.Context, prompt string, opts fundament.GenerationOptions, fn func(fundament.StreamChunk)) error {
+	if fn == nil {
+		return errors.New("cassette: stream callback must not be nil")
+	}
+	in, err := s.find(KindRespondStream, prompt, opts, fundament.Schema{})
+	if err != nil {
+		return err
+	}
+	var elapsed time.Duration
+	for _, chunk := range in.Chunks {
+		offset := time.Duration(chunk.Offset)
+		if err := s.sleep(ctx, offset-elapsed); err != nil {
+			return err
+		}
+		elapsed = offset
+		fn(fundament.StreamChunk{Text: chunk.Text, Final: chunk.Final, Warnings: chunk.Warnings, FinishReason: chunk.FinishReason})
+	}
+	if err := s.sleep(ctx, time.Duration(in.Duration)-elapsed); err != nil {
+		return err
+	}
+	return in.err()
+}
+
+func (s *replaySession) Close() error {
+	return nil
+}
+
+// sleep waits d when replaying in real time, returning early if ctx is done.
+func (s *replaySession) sleep(ctx context.Context, d time.Duration) error {
+	if !s.replayer.opts.RealTime || d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
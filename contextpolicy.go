@@ -0,0 +1,268 @@
+package fundament
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultContextWindow is the context size of the on-device SystemLanguageModel, in tokens.
+const DefaultContextWindow = 4096
+
+// ContextStrategy selects how a Session frees context space.
+type ContextStrategy int
+
+const (
+	// ContextTruncate drops the oldest turns and restores the rest into a fresh backend session.
+	// The backend must implement TranscriptBackend.
+	ContextTruncate ContextStrategy = iota
+	// ContextSummarize condenses the conversation, including the summary left by an earlier
+	// rollover, with a separate call and starts a fresh backend session whose instructions
+	// carry the new summary.
+	ContextSummarize
+)
+
+func (s ContextStrategy) String() string {
+	switch s {
+	case ContextTruncate:
+		return "truncate"
+	case ContextSummarize:
+		return "summarize"
+	default:
+		return fmt.Sprintf("ContextStrategy(%d)", int(s))
+	}
+}
+
+// summaryHeading separates the configured instructions from the summary a rollover adds.
+const summaryHeading = "Summary of the conversation so far:\n"
+
+// DefaultSummaryPrompt introduces the conversation in summarization calls.
+const DefaultSummaryPrompt = "Summarize the conversation below in a few sentences. Keep the names, facts, and decisions needed to continue it."
+
+// ContextPolicy keeps a Session within the model's context window. Before each call the
+// session estimates the tokens of its transcript plus the prompt and a response reserve,
+// and rolls the conversation over when the total would exceed Window.
+type ContextPolicy struct {
+	Strategy ContextStrategy
+	// Window defaults to DefaultContextWindow.
+	Window int
+	// Reserve is the room kept for the response. It defaults to 512 tokens, or to
+	// the call's WithMaxTokens value when set.
+	Reserve int
+	// Estimate counts the tokens of a text. It defaults to EstimateTokens.
+	Estimate func(text string) int
+	// SummaryPrompt defaults to DefaultSummaryPrompt.
+	SummaryPrompt string
+	// OnRollover is called after the conversation was rolled over.
+	OnRollover func(ContextRollover)
+}
+
+// ContextRollover describes a completed rollover.
+type ContextRollover struct {
+	Strategy ContextStrategy
+	// Dropped is the number of transcript entries removed or summarized.
+	Dropped      int
+	TokensBefore int
+	TokensAfter  int
+	// Summary is only set for ContextSummarize.
+	Summary string
+}
+
+// EstimateTokens approximates the token count of text at four characters per token.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
+
+func (p *ContextPolicy) window() int {
+	if p.Window > 0 {
+		return p.Window
+	}
+	return DefaultContextWindow
+}
+
+func (p *ContextPolicy) reserve(opts GenerationOptions) int {
+	if opts.MaxTokens != nil && *opts.MaxTokens > 0 {
+		return *opts.MaxTokens
+	}
+	if p.Reserve > 0 {
+		return p.Reserve
+	}
+	return 512
+}
+
+func (p *ContextPolicy) estimate(text string) int {
+	if text == "" {
+		return 0
+	}
+	if p.Estimate != nil {
+		return p.Estimate(text)
+	}
+	return EstimateTokens(text)
+}
+
+func (p *ContextPolicy) entryTokens(e TranscriptEntry) int {
+	return p.estimate(e.Text) + p.estimate(string(e.JSON)) + p.estimate(string(e.Schema)) + p.estimate(e.ToolName)
+}
+
+func (p *ContextPolicy) transcriptTokens(entries []TranscriptEntry) int {
+	total := 0
+	for _, e := range entries {
+		total += p.entryTokens(e)
+	}
+	return total
+}
+
+// ContextTokens returns the estimated token count of the transcript, using the session's
+// ContextPolicy estimator when one is configured.
+func (s *Session) ContextTokens() int {
+	p := s.policy
+	if p == nil {
+		p = &ContextPolicy{}
+	}
+	return p.transcriptTokens(s.Transcript().Entries)
+}
+
+// ensureContext rolls the conversation over when the next call would not fit the window.
+// request is the prompt plus any schema sent with it.
+func (s *Session) ensureContext(ctx context.Context, request string, opts GenerationOptions) error {
+	p := s.policy
+	if p == nil {
+		return nil
+	}
+	// The call queue keeps other calls out while the rollover runs, so the transcript can be
+	// read without s.mu. Holding s.mu through the summary call would block Close.
+	entries := s.Transcript().Entries
+	budget := p.window() - p.reserve(opts) - p.estimate(request)
+	before := p.transcriptTokens(entries)
+	if before <= budget {
+		return nil
+	}
+
+	head := 0
+	if len(entries) > 0 && entries[0].Kind == EntryInstructions {
+		head = 1
+	}
+	if len(entries) == head {
+		return nil
+	}
+
+	var (
+		bs         BackendSession
+		transcript Transcript
+		event      = ContextRollover{Strategy: p.Strategy, TokensBefore: before}
+		err        error
+	)
+	switch p.Strategy {
+	case ContextTruncate:
+		rest := entries[head:]
+		kept := p.transcriptTokens(entries[:head])
+		restTokens := before - kept
+		for len(rest) > 0 && kept+restTokens > budget {
+			// Drop whole turns: a prompt and everything that answered it.
+			n := 1
+			for n < len(rest) && rest[n].Kind != EntryPrompt {
+				n++
+			}
+			restTokens -= p.transcriptTokens(rest[:n])
+			rest = rest[n:]
+			event.Dropped += n
+		}
+		transcript.Entries = append(append([]TranscriptEntry(nil), entries[:head]...), rest...)
+		tb, ok := s.factory.(TranscriptBackend)
+		if !ok {
+			return fmt.Errorf("fundament: context truncation: %w", errTranscriptUnsupported)
+		}
+		bs, err = tb.NewSessionFromTranscript(transcript.clone())
+	case ContextSummarize:
+		event.Dropped = len(entries) - head
+		base, previous := s.instr, ""
+		if head == 1 {
+			base, previous = splitSummary(entries[0].Text)
+		}
+		event.Summary, err = s.summarize(ctx, p, previous, entries[head:])
+		if err != nil {
+			return fmt.Errorf("fundament: summarize conversation: %w", err)
+		}
+		instructions := summaryHeading + event.Summary
+		if base != "" {
+			instructions = base + "\n\n" + instructions
+		}
+		transcript.Entries = []TranscriptEntry{{Kind: EntryInstructions, Text: instructions}}
+		bs, err = s.factory.NewSession(instructions)
+	default:
+		return fmt.Errorf("fundament: unknown context strategy %v", p.Strategy)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.swap(bs, transcript)
+
+	event.TokensAfter = p.transcriptTokens(transcript.Entries)
+	if p.OnRollover != nil {
+		p.OnRollover(event)
+	}
+	return nil
+}
+
+// splitSummary separates instructions written by an earlier rollover into the configured
+// instructions and the summary appended to them.
+func splitSummary(instructions string) (base, summary string) {
+	i := strings.LastIndex(instructions, summaryHeading)
+	if i < 0 {
+		return instructions, ""
+	}
+	return strings.TrimSuffix(instructions[:i], "\n\n"), instructions[i+len(summaryHeading):]
+}
+
+// summarize condenses entries, and the summary of any earlier rollover, with a one-off
+// session on the same backend.
+func (s *Session) summarize(ctx context.Context, p *ContextPolicy, previous string, entries []TranscriptEntry) (string, error) {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		switch e.Kind {
+		case EntryPrompt:
+			lines = append(lines, "User: "+e.Text)
+		case EntryResponse:
+			lines = append(lines, "Assistant: "+e.Text)
+		case EntryStructuredResponse:
+			lines = append(lines, "Assistant: "+string(e.JSON))
+		case EntryToolCall:
+			lines = append(lines, fmt.Sprintf("Tool call %s: %s", e.ToolName, e.JSON))
+		case EntryToolOutput:
+			lines = append(lines, fmt.Sprintf("Tool output %s: %s", e.ToolName, e.Text))
+		}
+	}
+
+	prompt := p.SummaryPrompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	// The summary call has the same window, so drop the oldest lines it cannot fit. The
+	// earlier summary always goes in, or a second rollover would forget what the first kept.
+	budget := p.window() - p.reserve(GenerationOptions{}) - p.estimate(prompt)
+	var earlier []string
+	if previous != "" {
+		earlier = []string{"Earlier summary: " + previous}
+		budget -= p.estimate(earlier[0])
+	}
+	used := 0
+	start := len(lines)
+	for start > 0 && used+p.estimate(lines[start-1]) <= budget {
+		start--
+		used += p.estimate(lines[start])
+	}
+	lines = append(earlier, lines[start:]...)
+
+	bs, err := s.factory.NewSession("")
+	if err != nil {
+		return "", err
+	}
+	defer bs.Close()
+	resp, err := bs.Respond(ctx, prompt+"\n\n"+strings.Join(lines, "\n"), GenerationOptions{})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Text), nil
+}
@@ -0,0 +1,192 @@
+package fundament_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+// charPolicy counts one token per byte so budgets are easy to reason about.
+func charPolicy(strategy fundament.ContextStrategy, window int, events *[]fundament.ContextRollover) *fundament.ContextPolicy {
+	return &fundament.ContextPolicy{
+		Strategy:   strategy,
+		Window:     window,
+		Reserve:    1,
+		Estimate:   func(s string) int { return len(s) },
+		OnRollover: func(ev fundament.ContextRollover) { *events = append(*events, ev) },
+	}
+}
+
+func TestContextPolicyTruncatesOldestTurns(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("bbbbbbbbbb")
+	var events []fundament.ContextRollover
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{
+		Instructions:  "sys",
+		ContextPolicy: charPolicy(fundament.ContextTruncate, 40, &events),
+	})
+
+	for _, prompt := range []string{"aaaaaaaaa1", "aaaaaaaaa2", "aaaaaaaaa3"} {
+		if _, err := session.Respond(context.Background(), prompt); err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected one rollover, got %+v", events)
+	}
+	if ev := events[0]; ev.Strategy != fundament.ContextTruncate || ev.Dropped != 2 || ev.TokensBefore != 43 || ev.TokensAfter != 23 {
+		t.Fatalf("unexpected rollover %+v", ev)
+	}
+	calls := model.Calls()
+	last := calls[len(calls)-1]
+	if entries := last.Transcript.Entries; len(entries) != 3 || entries[0].Text != "sys" || entries[1].Text != "aaaaaaaaa2" {
+		t.Fatalf("fresh session not seeded with remaining turns: %+v", entries)
+	}
+	if model.OpenSessions() != 1 {
+		t.Fatalf("replaced backend session must be closed, %d open", model.OpenSessions())
+	}
+	if got := session.ContextTokens(); got != 43 {
+		t.Fatalf("ContextTokens = %d, want 43", got)
+	}
+}
+
+func TestContextPolicySummarizes(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Regexp(`^Sum:`)).Reply("The user likes tea.")
+	model.On(fundamenttest.Any()).Reply("bbbbbbbbbb")
+	var events []fundament.ContextRollover
+	policy := charPolicy(fundament.ContextSummarize, 50, &events)
+	policy.SummaryPrompt = "Sum:"
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{
+		Instructions:  "sys",
+		ContextPolicy: policy,
+	})
+
+	for _, prompt := range []string{"aaaaaaaaa1", "aaaaaaaaa2", "aaaaaaaaa3"} {
+		if _, err := session.Respond(context.Background(), prompt); err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+	}
+
+	if len(events) != 1 || events[0].Summary != "The user likes tea." || events[0].Dropped != 4 {
+		t.Fatalf("unexpected rollovers %+v", events)
+	}
+	calls := model.Calls()
+	if len(calls) != 4 {
+		t.Fatalf("expected summary call plus three turns, got %+v", calls)
+	}
+	summary := calls[2]
+	// Only the newest lines fit the summary call's own window.
+	if summary.Prompt != "Sum:\n\nUser: aaaaaaaaa2\nAssistant: bbbbbbbbbb" {
+		t.Fatalf("unexpected summary prompt %q", summary.Prompt)
+	}
+	wantInstructions := "sys\n\nSummary of the conversation so far:\nThe user likes tea."
+	if calls[3].Instructions != wantInstructions {
+		t.Fatalf("fresh session instructions = %q", calls[3].Instructions)
+	}
+	entries := session.Transcript().Entries
+	if len(entries) != 3 || entries[0].Text != wantInstructions || entries[1].Text != "aaaaaaaaa3" {
+		t.Fatalf("unexpected transcript after rollover %+v", entries)
+	}
+	if session.Instructions() != "sys" {
+		t.Fatalf("Instructions must keep the configured value, got %q", session.Instructions())
+	}
+	if model.OpenSessions() != 1 {
+		t.Fatalf("summary and replaced sessions must be closed, %d open", model.OpenSessions())
+	}
+}
+
+func TestContextPolicyCarriesSummaryAcrossRollovers(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Regexp(`^Sum:\n\nEarlier summary: Likes tea\.\n`)).Reply("Likes tea and cake.")
+	model.On(fundamenttest.Regexp(`^Sum:`)).Reply("Likes tea.")
+	model.On(fundamenttest.Any()).Reply("bbbbbbbbbb")
+	var events []fundament.ContextRollover
+	policy := charPolicy(fundament.ContextSummarize, 90, &events)
+	policy.SummaryPrompt = "Sum:"
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{
+		Instructions:  "sys",
+		ContextPolicy: policy,
+	})
+
+	for _, prompt := range []string{"aaaaaaaaa1", "aaaaaaaaa2", "aaaaaaaaa3", "aaaaaaaaa4", "aaaaaaaaa5", "aaaaaaaaa6", "aaaaaaaaa7"} {
+		if _, err := session.Respond(context.Background(), prompt); err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+	}
+
+	if len(events) != 2 || events[1].Summary != "Likes tea and cake." {
+		t.Fatalf("unexpected rollovers %+v", events)
+	}
+	want := "sys\n\nSummary of the conversation so far:\nLikes tea and cake."
+	if entries := session.Transcript().Entries; entries[0].Text != want {
+		t.Fatalf("second rollover must replace the first summary, got %q", entries[0].Text)
+	}
+}
+
+func TestContextPolicyCloseDuringSummary(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Regexp(`^Sum:`)).Reply("Likes tea.").Delay(time.Hour)
+	model.On(fundamenttest.Any()).Reply("bbbbbbbbbb")
+	var events []fundament.ContextRollover
+	policy := charPolicy(fundament.ContextSummarize, 30, &events)
+	policy.SummaryPrompt = "Sum:"
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{ContextPolicy: policy})
+
+	if _, err := session.Respond(context.Background(), "aaaaaaaaa1"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	result := make(chan error, 1)
+	go func() {
+		_, err := session.Respond(context.Background(), "aaaaaaaaa2")
+		result <- err
+	}()
+	deadline := time.Now().Add(time.Second)
+	for len(model.Calls()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("summary call never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- session.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked behind the summary call")
+	}
+	if err := <-result; !errors.Is(err, fundament.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestContextPolicyTruncateRequiresTranscriptBackend(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	type plainBackend struct{ fundament.Backend }
+	var events []fundament.ContextRollover
+	session, err := fundament.NewSession(fundament.SessionOptions{
+		Backend:       plainBackend{model},
+		ContextPolicy: charPolicy(fundament.ContextTruncate, 40, &events),
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	if _, err := session.Respond(context.Background(), "first"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "second"); err == nil {
+		t.Fatal("expected truncation to fail without TranscriptBackend")
+	}
+	if len(events) != 0 {
+		t.Fatalf("no rollover expected, got %+v", events)
+	}
+}
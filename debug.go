@@ -0,0 +1,127 @@
+package fundament
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DebugEnv is the environment variable that enables debug mode at startup when set to a
+// value other than "" or "0".
+const DebugEnv = "FUNDAMENT_DEBUG"
+
+var debugEnabled atomic.Bool
+
+func init() {
+	if v := os.Getenv(DebugEnv); v != "" && v != "0" {
+		debugEnabled.Store(true)
+	}
+}
+
+// SetDebug turns leak detection on or off. While enabled, sessions record the stack that
+// created them, are listed by LiveSessions until closed, and are reported through log/slog if
+// they are garbage-collected without Close. Sessions created while disabled are not tracked.
+func SetDebug(enabled bool) {
+	debugEnabled.Store(enabled)
+}
+
+// LiveSession describes a tracked session that has not been closed.
+type LiveSession struct {
+	Created      time.Time
+	Instructions string
+	// Stack is the call stack of the NewSession or Fork call that created the session.
+	Stack string
+	// Collected reports that the session was garbage-collected without Close; its backend
+	// resources were never released.
+	Collected bool
+}
+
+// leakRecord is kept apart from the Session so the registry does not keep sessions alive.
+type leakRecord struct {
+	created      time.Time
+	instructions string
+	stack        string
+	collected    bool
+}
+
+var liveSessions struct {
+	mu      sync.Mutex
+	records map[*leakRecord]struct{}
+}
+
+// LiveSessions returns the sessions created in debug mode that have not been closed, oldest
+// first. Tests can check it is empty at the end of TestMain.
+func LiveSessions() []LiveSession {
+	liveSessions.mu.Lock()
+	out := make([]LiveSession, 0, len(liveSessions.records))
+	for r := range liveSessions.records {
+		out = append(out, LiveSession{Created: r.created, Instructions: r.instructions, Stack: r.stack, Collected: r.collected})
+	}
+	liveSessions.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Created.Before(out[j].Created) })
+	return out
+}
+
+// track registers s when debug mode is enabled.
+func (s *Session) track() {
+	if !debugEnabled.Load() {
+		return
+	}
+	r := &leakRecord{created: s.created, instructions: s.instr, stack: callerStack(2)}
+	liveSessions.mu.Lock()
+	if liveSessions.records == nil {
+		liveSessions.records = make(map[*leakRecord]struct{})
+	}
+	liveSessions.records[r] = struct{}{}
+	liveSessions.mu.Unlock()
+	s.leak = r
+	runtime.AddCleanup(s, reportLeak, r)
+}
+
+// untrack removes s from the registry once it has been closed.
+func (s *Session) untrack() {
+	if s.leak == nil {
+		return
+	}
+	liveSessions.mu.Lock()
+	delete(liveSessions.records, s.leak)
+	liveSessions.mu.Unlock()
+}
+
+func reportLeak(r *leakRecord) {
+	liveSessions.mu.Lock()
+	_, open := liveSessions.records[r]
+	if open {
+		r.collected = true
+	}
+	liveSessions.mu.Unlock()
+	if open {
+		slog.Warn("fundament: session garbage-collected without Close",
+			"created", r.created,
+			"instructions", r.instructions,
+			"stack", r.stack)
+	}
+}
+
+// callerStack formats the calling goroutine's stack without callerStack and the skip frames
+// above it, one "function\n\tfile:line" pair per frame.
+func callerStack(skip int) string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
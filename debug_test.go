@@ -0,0 +1,104 @@
+package fundament
+
+import (
+	"bytes"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func withDebug(t *testing.T) {
+	t.Helper()
+	SetDebug(true)
+	t.Cleanup(func() { SetDebug(false) })
+}
+
+func TestLiveSessionsTracksUnclosedSessions(t *testing.T) {
+	withDebug(t)
+	session, err := NewSession(SessionOptions{Instructions: "tracked", Backend: &stubBackend{}})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+
+	live := LiveSessions()
+	if len(live) != 1 {
+		t.Fatalf("expected one live session, got %d", len(live))
+	}
+	if live[0].Instructions != "tracked" || live[0].Collected {
+		t.Fatalf("unexpected live session %+v", live[0])
+	}
+	if !strings.Contains(live[0].Stack, "TestLiveSessionsTracksUnclosedSessions") {
+		t.Fatalf("stack must start at the caller of NewSession:\n%s", live[0].Stack)
+	}
+
+	session.Close()
+	if live := LiveSessions(); len(live) != 0 {
+		t.Fatalf("closed session still listed: %+v", live)
+	}
+}
+
+func TestLiveSessionsIgnoresSessionsCreatedWithoutDebug(t *testing.T) {
+	session, err := NewSession(SessionOptions{Backend: &stubBackend{}})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	if live := LiveSessions(); len(live) != 0 {
+		t.Fatalf("untracked session listed: %+v", live)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the cleanup goroutine to write to.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLeakedSessionIsReported(t *testing.T) {
+	withDebug(t)
+	var out syncBuffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&out, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	func() {
+		if _, err := NewSession(SessionOptions{Backend: &stubBackend{}}); err != nil {
+			t.Fatalf("NewSession error: %v", err)
+		}
+	}()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "without Close") {
+		if time.Now().After(deadline) {
+			t.Fatal("leaked session was not reported")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.Contains(out.String(), "TestLeakedSessionIsReported") {
+		t.Fatalf("report must include the creation stack: %s", out.String())
+	}
+	live := LiveSessions()
+	if len(live) != 1 || !live[0].Collected {
+		t.Fatalf("expected the leaked session listed as collected, got %+v", live)
+	}
+	// Forget the record so later tests start clean.
+	liveSessions.mu.Lock()
+	clear(liveSessions.records)
+	liveSessions.mu.Unlock()
+}
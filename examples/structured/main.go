@@ -10,6 +10,13 @@ import (
 	"github.com/domano/fundament"
 )
 
+// TravelPlan is a short trip plan; SchemaFor derives the generation schema from its tags.
+type TravelPlan struct {
+	Destination string   `json:"destination" fundament:"description=City and country for the trip."`
+	Highlights  []string `json:"highlights" fundament:"description=A list of must-see highlights.,min=2,max=4"`
+	PackingList []string `json:"packingList" fundament:"description=Packing list tailored to the itinerary."`
+}
+
 func main() {
 	availability, err := fundament.CheckAvailability()
 	if err != nil {
@@ -27,60 +34,17 @@ func main() {
 	}
 	defer session.Close()
 
-	schemaDefinition := map[string]any{
-		"name":        "TravelPlan",
-		"description": "A short trip plan with destination, highlights, and packing list.",
-		"properties": []map[string]any{
-			{
-				"name": "destination",
-				"schema": map[string]any{
-					"type":        "string",
-					"description": "City and country for the trip.",
-				},
-			},
-			{
-				"name": "highlights",
-				"schema": map[string]any{
-					"type":            "array",
-					"description":     "A list of must-see highlights.",
-					"minimumElements": 2,
-					"maximumElements": 4,
-					"items": map[string]any{
-						"type":        "string",
-						"description": "Concise highlight.",
-					},
-				},
-			},
-			{
-				"name": "packingList",
-				"schema": map[string]any{
-					"type":        "array",
-					"description": "Packing list tailored to the itinerary.",
-					"items": map[string]any{
-						"type":        "string",
-						"description": "Single packing list item.",
-					},
-				},
-			},
-		},
-	}
-
-	schemaBytes, err := json.Marshal(schemaDefinition)
-	if err != nil {
-		log.Fatalf("marshal schema: %v", err)
-	}
-	schema, err := fundament.SchemaFromRawJSON(schemaBytes)
-	if err != nil {
-		log.Fatalf("schema: %v", err)
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	res, err := session.RespondStructured(ctx, "Plan a 2-day trip to Kyoto in autumn", schema)
+	plan, err := fundament.RespondAs[TravelPlan](ctx, session, "Plan a 2-day trip to Kyoto in autumn")
 	if err != nil {
 		log.Fatalf("respond structured: %v", err)
 	}
 
-	fmt.Println(string(res.JSON))
+	out, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		log.Fatalf("marshal plan: %v", err)
+	}
+	fmt.Println(string(out))
 }
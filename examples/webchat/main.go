@@ -30,9 +30,11 @@ func initialMessages() []message {
 	}
 }
 
+const instructions = "You are a friendly assistant answering questions on a website chat widget. Keep responses short and helpful."
+
 type chatServer struct {
-	session *fundament.Session
 	mu      sync.Mutex
+	session *fundament.Session
 	history []message
 }
 
@@ -49,12 +51,13 @@ func (s *chatServer) handleChat(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		prompt := s.appendUserAndPrompt(userMessage)
+		session := s.appendUserMessage(userMessage)
 
 		ctx, cancel := context.WithTimeout(r.Context(), 45*time.Second)
 		defer cancel()
 
-		resp, err := s.session.Respond(ctx, prompt)
+		// The session keeps the conversation history, so only the new message is sent.
+		resp, err := session.Respond(ctx, userMessage)
 		if err != nil {
 			log.Printf("respond: %v", err)
 			s.appendSystemMessage(fmt.Sprintf("Response error: %v", err))
@@ -67,7 +70,9 @@ func (s *chatServer) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if r.Method == http.MethodGet && r.URL.Query().Get("reset") == "1" {
-		s.resetConversation()
+		if err := s.resetConversation(); err != nil {
+			log.Printf("reset: %v", err)
+		}
 		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
 		return
 	}
@@ -81,11 +86,11 @@ func (s *chatServer) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (s *chatServer) appendUserAndPrompt(content string) string {
+func (s *chatServer) appendUserMessage(content string) *fundament.Session {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.history = append(s.history, message{Role: "user", Content: content})
-	return buildPrompt(s.history)
+	return s.session
 }
 
 func (s *chatServer) appendAssistantMessage(content string) {
@@ -100,10 +105,17 @@ func (s *chatServer) appendSystemMessage(content string) {
 	s.history = append(s.history, message{Role: "system", Content: content})
 }
 
-func (s *chatServer) resetConversation() {
+func (s *chatServer) resetConversation() error {
+	session, err := fundament.NewSession(fundament.SessionOptions{Instructions: instructions})
+	if err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.session.Close()
+	s.session = session
 	s.history = initialMessages()
+	return nil
 }
 
 func (s *chatServer) historySnapshot() []message {
@@ -114,25 +126,6 @@ func (s *chatServer) historySnapshot() []message {
 	return out
 }
 
-func buildPrompt(history []message) string {
-	var b strings.Builder
-	b.WriteString("Continue the conversation between the user and the assistant.\n\n")
-	for _, msg := range history {
-		switch msg.Role {
-		case "assistant":
-			b.WriteString("Assistant: ")
-		case "system":
-			b.WriteString("System: ")
-		default:
-			b.WriteString("User: ")
-		}
-		b.WriteString(msg.Content)
-		b.WriteByte('\n')
-	}
-	b.WriteString("Assistant:")
-	return b.String()
-}
-
 func main() {
 	availability, err := fundament.CheckAvailability()
 	if err != nil {
@@ -143,7 +136,7 @@ func main() {
 	}
 
 	session, err := fundament.NewSession(fundament.SessionOptions{
-		Instructions: "You are a friendly assistant answering questions on a website chat widget. Keep responses short and helpful.",
+		Instructions: instructions,
 	})
 	if err != nil {
 		log.Fatalf("new session: %v", err)
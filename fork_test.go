@@ -0,0 +1,176 @@
+package fundament_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+func TestForkBranchesIndependently(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Exact("one")).Reply("1")
+	model.On(fundamenttest.Exact("two")).Reply("2")
+	model.On(fundamenttest.Any()).Reply("other")
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{Instructions: "count"})
+
+	for _, prompt := range []string{"one", "two"} {
+		if _, err := session.Respond(context.Background(), prompt); err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+	}
+
+	// Regenerate the second answer: branch just before its prompt.
+	fork, err := session.Fork(3)
+	if err != nil {
+		t.Fatalf("Fork error: %v", err)
+	}
+	defer fork.Close()
+	if fork.Instructions() != "count" {
+		t.Fatalf("fork instructions = %q", fork.Instructions())
+	}
+	if _, err := fork.Respond(context.Background(), "edited"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "three"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+
+	texts := func(s *fundament.Session) []string {
+		var out []string
+		for _, e := range s.Transcript().Entries {
+			out = append(out, e.Text)
+		}
+		return out
+	}
+	assertTexts(t, "original", texts(session), "count", "one", "1", "two", "2", "three", "other")
+	assertTexts(t, "fork", texts(fork), "count", "one", "1", "edited", "other")
+
+	calls := model.Calls()
+	forkCall := calls[2]
+	if forkCall.Prompt != "edited" || len(forkCall.Transcript.Entries) != 3 {
+		t.Fatalf("fork backend not seeded with the branch point: %+v", forkCall)
+	}
+
+	// Forks of forks stay independent as well.
+	again, err := fork.Fork(0)
+	if err != nil {
+		t.Fatalf("Fork error: %v", err)
+	}
+	defer again.Close()
+	assertTexts(t, "fork of fork", texts(again), "count")
+	assertTexts(t, "fork after refork", texts(fork), "count", "one", "1", "edited", "other")
+}
+
+func TestForkErrors(t *testing.T) {
+	model := fundamenttest.NewModel()
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{})
+	if _, err := session.Fork(1); err == nil {
+		t.Fatal("expected out of range error")
+	}
+	model.On(fundamenttest.Any()).Reply("answer")
+	if _, err := session.Respond(context.Background(), "question"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	// Index 1 is the response: keeping entry 0 alone would leave the prompt unanswered.
+	if _, err := session.Fork(1); err == nil {
+		t.Fatal("expected an error for a fork between a prompt and its response")
+	}
+	fork, err := session.Fork(2)
+	if err != nil || len(fork.Transcript().Entries) != 2 {
+		t.Fatalf("forking at the end must keep the whole transcript, got %v", err)
+	}
+	fork.Close()
+	session.Close()
+	if _, err := session.Fork(0); !errors.Is(err, fundament.ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
+	}
+}
+
+func TestForkReplaysWithoutTranscriptBackend(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Exact("one")).Reply("1")
+	model.On(fundamenttest.Exact("city")).ReplyJSON(`{"city":"Oslo"}`)
+	model.On(fundamenttest.Any()).Reply("other")
+	type plainBackend struct{ fundament.Backend }
+	session, err := fundament.NewSession(fundament.SessionOptions{
+		Instructions: "count",
+		Backend:      plainBackend{model},
+		Defaults:     []fundament.GenerationOption{fundament.WithTemperature(0.2)},
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	schema, err := fundament.SchemaFromRawJSON([]byte(`{"name":"City","properties":[{"name":"city","schema":{"type":"string"}}]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "one"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if _, err := session.RespondStructured(context.Background(), "city", schema); err != nil {
+		t.Fatalf("RespondStructured error: %v", err)
+	}
+
+	fork, err := session.Fork(5)
+	if err != nil {
+		t.Fatalf("Fork error: %v", err)
+	}
+	defer fork.Close()
+	entries := fork.Transcript().Entries
+	if len(entries) != 5 || entries[2].Text != "1" || string(entries[4].JSON) != `{"city":"Oslo"}` {
+		t.Fatalf("unexpected fork transcript %+v", entries)
+	}
+	calls := model.Calls()
+	replayed := calls[2:]
+	if len(replayed) != 2 || replayed[0].Prompt != "one" || replayed[0].Instructions != "count" || replayed[1].Prompt != "city" {
+		t.Fatalf("fork did not replay the prompts: %+v", replayed)
+	}
+	if got := replayed[0].Options.Temperature; got == nil || *got != 0.2 {
+		t.Fatalf("replay must use the session defaults, got %v", got)
+	}
+
+	// A branch ending on an unanswered prompt cannot be replayed.
+	if _, err := session.Fork(2); err == nil {
+		t.Fatal("expected an error for a prompt without its response")
+	}
+	if model.OpenSessions() != 2 {
+		t.Fatalf("failed replay must close its backend session, %d open", model.OpenSessions())
+	}
+}
+
+func assertTexts(t *testing.T, name string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %q, want %q", name, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestForkKeepsDefaults(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("ok")
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{
+		Defaults: []fundament.GenerationOption{fundament.WithMaxTokens(100)},
+	})
+
+	fork, err := session.Fork(0)
+	if err != nil {
+		t.Fatalf("Fork error: %v", err)
+	}
+	defer fork.Close()
+	if _, err := fork.Respond(context.Background(), "hi"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	calls := model.Calls()
+	if got := calls[len(calls)-1].Options.MaxTokens; got == nil || *got != 100 {
+		t.Fatalf("fork must keep the session defaults, got %v", got)
+	}
+}
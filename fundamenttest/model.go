@@ -0,0 +1,366 @@
+// Package fundamenttest provides a scriptable in-memory model for testing code built on fundament.Session.
+//
+// A Model implements fundament.Backend, so it works on every platform without the Swift shim:
+//
+//	model := fundamenttest.NewModel()
+//	model.On(fundamenttest.Exact("ping")).Reply("pong")
+//	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{})
+package fundamenttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+// CallKind identifies the session method that produced a Call.
+type CallKind int
+
+const (
+	CallRespond CallKind = iota
+	CallRespondStructured
+	CallRespondStream
+)
+
+func (k CallKind) String() string {
+	switch k {
+	case CallRespond:
+		return "Respond"
+	case CallRespondStructured:
+		return "RespondStructured"
+	case CallRespondStream:
+		return "RespondStream"
+	default:
+		return fmt.Sprintf("CallKind(%d)", int(k))
+	}
+}
+
+// Call records a single request received by the Model.
+type Call struct {
+	Kind         CallKind
+	Instructions string
+	Prompt       string
+	Options      fundament.GenerationOptions
+	// Schema is only set for structured calls.
+	Schema fundament.Schema
+	// Transcript is the transcript the session was restored from, if any.
+	Transcript fundament.Transcript
+}
+
+// Matcher reports whether a rule applies to a call.
+type Matcher func(Call) bool
+
+// Exact matches prompts equal to s.
+func Exact(s string) Matcher {
+	return func(c Call) bool {
+		return c.Prompt == s
+	}
+}
+
+// Regexp matches prompts against the regular expression expr. It panics if expr does not compile.
+func Regexp(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return func(c Call) bool {
+		return re.MatchString(c.Prompt)
+	}
+}
+
+// Func matches prompts for which fn returns true.
+func Func(fn func(prompt string) bool) Matcher {
+	return func(c Call) bool {
+		return fn(c.Prompt)
+	}
+}
+
+// Any matches every call.
+func Any() Matcher {
+	return func(Call) bool {
+		return true
+	}
+}
+
+// Rule scripts the reply for calls accepted by its Matcher. Configure it with the chainable setters.
+type Rule struct {
+	match  Matcher
+	text   string
+	json   string
+	chunks []string
+	err    error
+	delay  time.Duration
+	limit  int // 0 means unlimited
+	used   int
+}
+
+// Reply sets the text returned by Respond. Streams deliver it as a single final chunk unless Stream is set.
+func (r *Rule) Reply(text string) *Rule {
+	r.text = text
+	return r
+}
+
+// ReplyJSON sets the JSON returned by RespondStructured.
+func (r *Rule) ReplyJSON(json string) *Rule {
+	r.json = json
+	return r
+}
+
+// Stream sets the chunk sequence delivered by RespondStream. Respond returns the chunks joined when Reply is unset.
+func (r *Rule) Stream(chunks ...string) *Rule {
+	r.chunks = append([]string(nil), chunks...)
+	return r
+}
+
+// Fail makes matching calls return err.
+func (r *Rule) Fail(err error) *Rule {
+	r.err = err
+	return r
+}
+
+// Delay waits d before replying; streams wait d before each chunk. Cancelling the context aborts the wait.
+func (r *Rule) Delay(d time.Duration) *Rule {
+	r.delay = d
+	return r
+}
+
+// Times limits the rule to n matches, after which later rules are consulted.
+func (r *Rule) Times(n int) *Rule {
+	r.limit = n
+	return r
+}
+
+// Model is a fake fundament.Backend whose replies are scripted with On.
+type Model struct {
+	mu              sync.Mutex
+	rules           []*Rule
+	calls           []Call
+	availability    fundament.Availability
+	availabilityErr error
+	open            int
+	prewarms        []string
+}
+
+// NewModel returns a Model that reports itself as available and has no rules.
+func NewModel() *Model {
+	return &Model{
+		availability: fundament.Availability{State: fundament.AvailabilityReady, Reason: fundament.AvailabilityReasonNone},
+	}
+}
+
+// On registers a rule for calls accepted by match. Rules are consulted in registration order.
+func (m *Model) On(match Matcher) *Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := &Rule{match: match}
+	m.rules = append(m.rules, r)
+	return r
+}
+
+// SetAvailability changes the result of CheckAvailability.
+func (m *Model) SetAvailability(a fundament.Availability, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.availability = a
+	m.availabilityErr = err
+}
+
+// Calls returns a copy of every call received so far, in arrival order.
+func (m *Model) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Prewarms returns the prompt prefixes of every Session.Prewarm call received so far.
+func (m *Model) Prewarms() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.prewarms...)
+}
+
+// OpenSessions reports how many sessions were created and not yet closed.
+func (m *Model) OpenSessions() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.open
+}
+
+// Reset discards all rules and recorded calls.
+func (m *Model) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = nil
+	m.calls = nil
+	m.prewarms = nil
+}
+
+// NewSession implements fundament.Backend.
+func (m *Model) NewSession(instructions string) (fundament.BackendSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open++
+	return &modelSession{model: m, instructions: instructions}, nil
+}
+
+// NewSessionFromTranscript implements fundament.TranscriptBackend. The transcript is reported
+// on every Call the session receives.
+func (m *Model) NewSessionFromTranscript(t fundament.Transcript) (fundament.BackendSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.open++
+	return &modelSession{model: m, instructions: t.Instructions(), transcript: t}, nil
+}
+
+// CheckAvailability implements fundament.Backend.
+func (m *Model) CheckAvailability() (fundament.Availability, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.availability, m.availabilityErr
+}
+
+// record stores the call and returns the first rule that matches it.
+func (m *Model) record(call Call) (Rule, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, call)
+	for _, r := range m.rules {
+		if r.match != nil && !r.match(call) {
+			continue
+		}
+		if r.limit > 0 && r.used >= r.limit {
+			continue
+		}
+		r.used++
+		return *r, nil
+	}
+	return Rule{}, fmt.Errorf("fundamenttest: no rule matches %s prompt %q", call.Kind, call.Prompt)
+}
+
+type modelSession struct {
+	model        *Model
+	instructions string
+	transcript   fundament.Transcript
+	closed       bool
+}
+
+func (s *modelSession) call(kind CallKind, prompt string, opts fundament.GenerationOptions) Call {
+	return Call{Kind: kind, Instructions: s.instructions, Prompt: prompt, Options: opts, Transcript: s.transcript}
+}
+
+func (s *modelSession) Prewarm(ctx context.Context, promptPrefix string) error {
+	s.model.mu.Lock()
+	defer s.model.mu.Unlock()
+	s.model.prewarms = append(s.model.prewarms, promptPrefix)
+	return ctx.Err()
+}
+
+func (s *modelSession) Respond(ctx context.Context, prompt string, opts fundament.GenerationOptions) (fundament.Response, error) {
+	rule, err := s.model.record(s.call(CallRespond, prompt, opts))
+	if err != nil {
+		return fundament.Response{}, err
+	}
+	if err := wait(ctx, rule.delay); err != nil {
+		return fundament.Response{}, err
+	}
+	if rule.err != nil {
+		return fundament.Response{}, rule.err
+	}
+	text := rule.text
+	if text == "" && len(rule.chunks) > 0 {
+		text = strings.Join(rule.chunks, "")
+	}
+	return fundament.Response{Text: text}, nil
+}
+
+func (s *modelSession) RespondStructured(ctx context.Context, prompt string, schema fundament.Schema, opts fundament.GenerationOptions) (fundament.StructuredResponse, error) {
+	call := s.call(CallRespondStructured, prompt, opts)
+	call.Schema = schema
+	rule, err := s.model.record(call)
+	if err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	if err := wait(ctx, rule.delay); err != nil {
+		return fundament.StructuredResponse{}, err
+	}
+	if rule.err != nil {
+		return fundament.StructuredResponse{}, rule.err
+	}
+	payload := rule.json
+	if payload == "" {
+		payload = rule.text
+	}
+	return fundament.StructuredResponse{JSON: []byte(payload)}, nil
+}
+
+func (s *modelSession) RespondStream(ctx context.Context, prompt string, opts fundament.GenerationOptions, fn func(fundament.StreamChunk)) error {
+	if fn == nil {
+		return errors.New("fundamenttest: stream callback must not be nil")
+	}
+	rule, err := s.model.record(s.call(CallRespondStream, prompt, opts))
+	if err != nil {
+		return err
+	}
+	chunks := rule.chunks
+	if len(chunks) == 0 && rule.err == nil {
+		chunks = []string{rule.text}
+	}
+	for i, chunk := range chunks {
+		if err := wait(ctx, rule.delay); err != nil {
+			return err
+		}
+		fn(fundament.StreamChunk{Text: chunk, Final: i == len(chunks)-1 && rule.err == nil})
+	}
+	if rule.err != nil {
+		if err := wait(ctx, rule.delay); err != nil {
+			return err
+		}
+		return rule.err
+	}
+	return nil
+}
+
+func (s *modelSession) Close() error {
+	s.model.mu.Lock()
+	defer s.model.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.model.open--
+	}
+	return nil
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// NewSession creates a fundament.Session served by m and closes it when the test finishes.
+// opts.Backend is overwritten.
+func NewSession(t testing.TB, m *Model, opts fundament.SessionOptions) *fundament.Session {
+	t.Helper()
+	opts.Backend = m
+	session, err := fundament.NewSession(opts)
+	if err != nil {
+		t.Fatalf("fundamenttest: NewSession error: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := session.Close(); err != nil {
+			t.Errorf("fundamenttest: Close error: %v", err)
+		}
+	})
+	return session
+}
@@ -0,0 +1,199 @@
+package fundamenttest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+)
+
+func TestModelRespondMatchers(t *testing.T) {
+	model := NewModel()
+	model.On(Exact("ping")).Reply("pong")
+	model.On(Regexp(`^weather in \w+$`)).Reply("sunny")
+	model.On(Func(func(p string) bool { return strings.HasSuffix(p, "?") })).Reply("maybe")
+
+	session := NewSession(t, model, fundament.SessionOptions{Instructions: "be brief"})
+
+	cases := map[string]string{
+		"ping":             "pong",
+		"weather in Kyoto": "sunny",
+		"really?":          "maybe",
+	}
+	for prompt, want := range cases {
+		resp, err := session.Respond(context.Background(), prompt)
+		if err != nil {
+			t.Fatalf("Respond(%q) error: %v", prompt, err)
+		}
+		if resp.Text != want {
+			t.Fatalf("Respond(%q) = %q, want %q", prompt, resp.Text, want)
+		}
+	}
+
+	if _, err := session.Respond(context.Background(), "unscripted"); err == nil {
+		t.Fatal("expected error for unmatched prompt")
+	}
+}
+
+func TestModelRecordsCalls(t *testing.T) {
+	model := NewModel()
+	model.On(Exact("first")).Reply("ok")
+	model.On(Exact("second")).ReplyJSON(`{"ok":true}`)
+
+	session := NewSession(t, model, fundament.SessionOptions{Instructions: "be brief"})
+	if _, err := session.Respond(context.Background(), "first", fundament.WithTemperature(0.3), fundament.WithMaxTokens(64)); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	schema, err := fundament.SchemaFromRawJSON([]byte(`{"name":"Result","properties":[{"name":"ok","schema":{"type":"boolean"}}]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	res, err := session.RespondStructured(context.Background(), "second", schema)
+	if err != nil {
+		t.Fatalf("RespondStructured error: %v", err)
+	}
+	if string(res.JSON) != `{"ok":true}` {
+		t.Fatalf("unexpected structured payload %s", res.JSON)
+	}
+
+	calls := model.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	first := calls[0]
+	if first.Kind != CallRespond || first.Prompt != "first" || first.Instructions != "be brief" {
+		t.Fatalf("unexpected first call %+v", first)
+	}
+	if first.Options.Temperature == nil || *first.Options.Temperature != 0.3 {
+		t.Fatalf("expected temperature 0.3, got %v", first.Options.Temperature)
+	}
+	if first.Options.MaxTokens == nil || *first.Options.MaxTokens != 64 {
+		t.Fatalf("expected max tokens 64, got %v", first.Options.MaxTokens)
+	}
+	if calls[1].Kind != CallRespondStructured || calls[1].Schema.String() != schema.String() {
+		t.Fatalf("unexpected second call %+v", calls[1])
+	}
+}
+
+func TestModelTimesFallsThrough(t *testing.T) {
+	model := NewModel()
+	model.On(Any()).ReplyJSON(`{"attempt":1}`).Times(1)
+	model.On(Any()).ReplyJSON(`{"attempt":2}`)
+
+	session := NewSession(t, model, fundament.SessionOptions{})
+	schema, err := fundament.SchemaFromRawJSON([]byte(`{"name":"Attempt","properties":[{"name":"attempt","schema":{"type":"integer"}}]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	for i, want := range []string{`{"attempt":1}`, `{"attempt":2}`, `{"attempt":2}`} {
+		res, err := session.RespondStructured(context.Background(), "go", schema)
+		if err != nil {
+			t.Fatalf("call %d error: %v", i, err)
+		}
+		if string(res.JSON) != want {
+			t.Fatalf("call %d = %s, want %s", i, res.JSON, want)
+		}
+	}
+}
+
+func TestModelStream(t *testing.T) {
+	model := NewModel()
+	model.On(Exact("count")).Stream("one ", "two ", "three")
+
+	session := NewSession(t, model, fundament.SessionOptions{})
+	ch, err := session.RespondStream(context.Background(), "count")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var got []fundament.StreamChunk
+	for chunk := range ch {
+		got = append(got, chunk)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 chunks, got %d", len(got))
+	}
+	if got[0].Text != "one " || got[0].Final || got[2].Text != "three" || !got[2].Final {
+		t.Fatalf("unexpected chunks %+v", got)
+	}
+
+	resp, err := session.Respond(context.Background(), "count")
+	if err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if resp.Text != "one two three" {
+		t.Fatalf("unexpected joined text %q", resp.Text)
+	}
+}
+
+func TestModelInjectedErrors(t *testing.T) {
+	boom := errors.New("boom")
+	model := NewModel()
+	model.On(Exact("fail")).Fail(boom)
+	model.On(Exact("partial")).Stream("half").Fail(boom)
+
+	session := NewSession(t, model, fundament.SessionOptions{})
+	if _, err := session.Respond(context.Background(), "fail"); !errors.Is(err, boom) {
+		t.Fatalf("expected injected error, got %v", err)
+	}
+
+	ch, err := session.RespondStream(context.Background(), "partial")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var got []fundament.StreamChunk
+	for chunk := range ch {
+		got = append(got, chunk)
+	}
+	if len(got) != 2 || got[0].Text != "half" || got[0].Final {
+		t.Fatalf("unexpected chunks %+v", got)
+	}
+	if !errors.Is(got[1].Err, boom) || !got[1].Final {
+		t.Fatalf("expected final error chunk, got %+v", got[1])
+	}
+}
+
+func TestModelDelayHonoursContext(t *testing.T) {
+	model := NewModel()
+	model.On(Any()).Reply("late").Delay(time.Second)
+
+	session := NewSession(t, model, fundament.SessionOptions{})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := session.Respond(ctx, "slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("delay ignored context cancellation")
+	}
+}
+
+func TestModelAvailabilityAndSessions(t *testing.T) {
+	model := NewModel()
+	got, err := model.CheckAvailability()
+	if err != nil || got.State != fundament.AvailabilityReady {
+		t.Fatalf("expected ready model, got %+v, %v", got, err)
+	}
+	model.SetAvailability(fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonModelNotReady}, nil)
+	got, _ = model.CheckAvailability()
+	if got.Reason != fundament.AvailabilityReasonModelNotReady {
+		t.Fatalf("unexpected availability %+v", got)
+	}
+
+	session, err := fundament.NewSession(fundament.SessionOptions{Backend: model})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	if model.OpenSessions() != 1 {
+		t.Fatalf("expected 1 open session, got %d", model.OpenSessions())
+	}
+	session.Close()
+	if model.OpenSessions() != 0 {
+		t.Fatalf("expected no open sessions, got %d", model.OpenSessions())
+	}
+}
@@ -9,6 +9,7 @@ extern "C" {
 #endif
 
 typedef void *fundament_session_ref;
+typedef void *fundament_cancel_token;
 
 typedef struct {
     int32_t code;
@@ -28,15 +29,25 @@ typedef struct {
 typedef void (*fundament_stream_cb)(const char *chunk, bool is_final, void *userdata);
 
 fundament_session_ref fundament_session_create(const char *instructions, fundament_error *out_error);
+fundament_session_ref fundament_session_create_with_transcript(const char *transcript_json, fundament_error *out_error);
 void fundament_session_destroy(fundament_session_ref session);
 
+bool fundament_session_prewarm(fundament_session_ref session, const char *prompt_prefix, fundament_error *out_error);
+
 bool fundament_session_check_availability(fundament_availability *out_availability, fundament_error *out_error);
 
-bool fundament_session_respond(fundament_session_ref session, const char *prompt, const char *options_json, fundament_buffer *out_buffer, fundament_error *out_error);
+// A cancel token aborts the call it is passed to. Pass NULL for calls that cannot be cancelled.
+fundament_cancel_token fundament_cancel_token_create(void);
+void fundament_cancel_token_cancel(fundament_cancel_token token);
+void fundament_cancel_token_destroy(fundament_cancel_token token);
+
+// Calls that generate a response write JSON metadata such as {"warnings":[...]} to out_metadata,
+// or leave it empty. Free it with fundament_buffer_free whether or not the call succeeded.
+bool fundament_session_respond(fundament_session_ref session, const char *prompt, const char *options_json, fundament_cancel_token cancel, fundament_buffer *out_buffer, fundament_buffer *out_metadata, fundament_error *out_error);
 
-bool fundament_session_respond_structured(fundament_session_ref session, const char *prompt, const char *schema_json, const char *options_json, fundament_buffer *out_buffer, fundament_error *out_error);
+bool fundament_session_respond_structured(fundament_session_ref session, const char *prompt, const char *schema_json, const char *options_json, fundament_cancel_token cancel, fundament_buffer *out_buffer, fundament_buffer *out_metadata, fundament_error *out_error);
 
-bool fundament_session_stream(fundament_session_ref session, const char *prompt, const char *options_json, fundament_stream_cb callback, void *userdata, fundament_error *out_error);
+bool fundament_session_stream(fundament_session_ref session, const char *prompt, const char *options_json, fundament_cancel_token cancel, fundament_stream_cb callback, void *userdata, fundament_buffer *out_metadata, fundament_error *out_error);
 
 void fundament_buffer_free(void *buffer);
 void fundament_error_free(void *error);
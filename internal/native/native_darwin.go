@@ -15,6 +15,9 @@ import (
 
 type SessionRef unsafe.Pointer
 
+// CancelToken aborts the Swift task of the call it is passed to.
+type CancelToken unsafe.Pointer
+
 type Availability struct {
 	State  int32
 	Reason int32
@@ -62,10 +65,15 @@ var (
 	registerErr  error
 
 	fnSessionCreate            func(*byte, *cError) SessionRef
+	fnSessionCreateTranscript  func(*byte, *cError) SessionRef
 	fnSessionDestroy           func(SessionRef)
-	fnSessionRespond           func(SessionRef, *byte, *byte, *cBuffer, *cError) bool
-	fnSessionRespondStructured func(SessionRef, *byte, *byte, *byte, *cBuffer, *cError) bool
-	fnSessionStream            func(SessionRef, *byte, *byte, fundamentStreamCallback, unsafe.Pointer, *cError) bool
+	fnSessionPrewarm           func(SessionRef, *byte, *cError) bool
+	fnSessionRespond           func(SessionRef, *byte, *byte, CancelToken, *cBuffer, *cBuffer, *cError) bool
+	fnSessionRespondStructured func(SessionRef, *byte, *byte, *byte, CancelToken, *cBuffer, *cBuffer, *cError) bool
+	fnSessionStream            func(SessionRef, *byte, *byte, CancelToken, fundamentStreamCallback, unsafe.Pointer, *cBuffer, *cError) bool
+	fnCancelTokenCreate        func() CancelToken
+	fnCancelTokenCancel        func(CancelToken)
+	fnCancelTokenDestroy       func(CancelToken)
 	fnSessionCheckAvailability func(*cAvailability, *cError) bool
 	fnBufferFree               func(unsafe.Pointer)
 	fnErrorFree                func(unsafe.Pointer)
@@ -94,9 +102,15 @@ func registerFunctions() error {
 	if err := shimloader.Register("fundament_session_create", &fnSessionCreate); err != nil {
 		return err
 	}
+	if err := shimloader.Register("fundament_session_create_with_transcript", &fnSessionCreateTranscript); err != nil {
+		return err
+	}
 	if err := shimloader.Register("fundament_session_destroy", &fnSessionDestroy); err != nil {
 		return err
 	}
+	if err := shimloader.Register("fundament_session_prewarm", &fnSessionPrewarm); err != nil {
+		return err
+	}
 	if err := shimloader.Register("fundament_session_respond", &fnSessionRespond); err != nil {
 		return err
 	}
@@ -109,6 +123,15 @@ func registerFunctions() error {
 	if err := shimloader.Register("fundament_session_check_availability", &fnSessionCheckAvailability); err != nil {
 		return err
 	}
+	if err := shimloader.Register("fundament_cancel_token_create", &fnCancelTokenCreate); err != nil {
+		return err
+	}
+	if err := shimloader.Register("fundament_cancel_token_cancel", &fnCancelTokenCancel); err != nil {
+		return err
+	}
+	if err := shimloader.Register("fundament_cancel_token_destroy", &fnCancelTokenDestroy); err != nil {
+		return err
+	}
 	if err := shimloader.Register("fundament_buffer_free", &fnBufferFree); err != nil {
 		return err
 	}
@@ -134,68 +157,119 @@ func SessionCreate(instructions string) (SessionRef, error) {
 	return ref, nil
 }
 
+func SessionCreateWithTranscript(transcriptJSON string) (SessionRef, error) {
+	cTranscript := newCString(transcriptJSON)
+
+	var cerr cError
+	ref := fnSessionCreateTranscript(cTranscript.ptrOrNil(), &cerr)
+	if err := takeError(&cerr); err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return nil, errors.New("fundament: session create failed without details")
+	}
+	return ref, nil
+}
+
 func SessionDestroy(ref SessionRef) {
 	fnSessionDestroy(ref)
 }
 
-func SessionRespond(ref SessionRef, prompt string, optionsJSON string) (string, error) {
+func SessionPrewarm(ref SessionRef, promptPrefix string) error {
+	cPrefix := newCString(promptPrefix)
+
+	var cerr cError
+	ok := fnSessionPrewarm(ref, cPrefix.ptrOrNil(), &cerr)
+	if err := takeError(&cerr); err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("fundament: prewarm failed without details")
+	}
+	return nil
+}
+
+// CancelTokenCreate returns a token to pass to a single call. Destroy it once the call returned.
+func CancelTokenCreate() CancelToken {
+	return fnCancelTokenCreate()
+}
+
+// CancelTokenCancel cancels the call the token was passed to. It is safe to call from any goroutine
+// until the token is destroyed.
+func CancelTokenCancel(token CancelToken) {
+	fnCancelTokenCancel(token)
+}
+
+func CancelTokenDestroy(token CancelToken) {
+	fnCancelTokenDestroy(token)
+}
+
+// SessionRespond returns the response text and the JSON metadata the shim reported with it,
+// which is empty when there is none.
+func SessionRespond(ref SessionRef, prompt string, optionsJSON string, cancel CancelToken) (string, string, error) {
 	cPrompt := newCString(prompt)
 	cOptions := newCString(optionsJSON)
 
-	var buf cBuffer
+	var buf, meta cBuffer
 	var cerr cError
-	ok := fnSessionRespond(ref, cPrompt.ptrOrNil(), cOptions.ptrOrNil(), &buf, &cerr)
+	ok := fnSessionRespond(ref, cPrompt.ptrOrNil(), cOptions.ptrOrNil(), cancel, &buf, &meta, &cerr)
+	metadata := takeBuffer(&meta)
 	if err := takeError(&cerr); err != nil {
-		return "", err
+		return "", "", err
 	}
 	if !ok {
-		return "", errors.New("fundament: respond failed without details")
+		return "", "", errors.New("fundament: respond failed without details")
 	}
 	if fnBufferFree != nil {
 		defer fnBufferFree(unsafe.Pointer(&buf))
 	}
-	return fromBuffer(&buf), nil
+	return fromBuffer(&buf), metadata, nil
 }
 
-func SessionRespondStructured(ref SessionRef, prompt, schemaJSON, optionsJSON string) (string, error) {
+func SessionRespondStructured(ref SessionRef, prompt, schemaJSON, optionsJSON string, cancel CancelToken) (string, string, error) {
 	cPrompt := newCString(prompt)
 	cSchema := newCString(schemaJSON)
 	cOptions := newCString(optionsJSON)
 
-	var buf cBuffer
+	var buf, meta cBuffer
 	var cerr cError
-	ok := fnSessionRespondStructured(ref, cPrompt.ptrOrNil(), cSchema.ptrOrNil(), cOptions.ptrOrNil(), &buf, &cerr)
+	ok := fnSessionRespondStructured(ref, cPrompt.ptrOrNil(), cSchema.ptrOrNil(), cOptions.ptrOrNil(), cancel, &buf, &meta, &cerr)
+	metadata := takeBuffer(&meta)
 	if err := takeError(&cerr); err != nil {
-		return "", err
+		return "", "", err
 	}
 	if !ok {
-		return "", errors.New("fundament: structured respond failed without details")
+		return "", "", errors.New("fundament: structured respond failed without details")
 	}
 	if fnBufferFree != nil {
 		defer fnBufferFree(unsafe.Pointer(&buf))
 	}
-	return fromBuffer(&buf), nil
+	return fromBuffer(&buf), metadata, nil
 }
 
-func SessionStream(ref SessionRef, prompt, optionsJSON string, cb StreamCallback) error {
+// SessionStream delivers chunks to cb and returns the JSON metadata the shim reported with the
+// response, which is empty when there is none.
+func SessionStream(ref SessionRef, prompt, optionsJSON string, cancel CancelToken, cb StreamCallback) (string, error) {
 	if cb == nil {
-		return errors.New("fundament: stream callback must not be nil")
+		return "", errors.New("fundament: stream callback must not be nil")
 	}
 	cPrompt := newCString(prompt)
 	cOptions := newCString(optionsJSON)
 
 	handlePtr := storeStreamCallback(cb)
+	var meta cBuffer
 	var cerr cError
-	ok := fnSessionStream(ref, cPrompt.ptrOrNil(), cOptions.ptrOrNil(), streamCallbackPtr, handlePtr, &cerr)
+	ok := fnSessionStream(ref, cPrompt.ptrOrNil(), cOptions.ptrOrNil(), cancel, streamCallbackPtr, handlePtr, &meta, &cerr)
+	metadata := takeBuffer(&meta)
 	if err := takeError(&cerr); err != nil {
 		releaseStreamCallback(handlePtr)
-		return err
+		return "", err
 	}
 	if !ok {
 		releaseStreamCallback(handlePtr)
-		return errors.New("fundament: streaming failed without details")
+		return "", errors.New("fundament: streaming failed without details")
 	}
-	return nil
+	return metadata, nil
 }
 
 func CheckAvailability() (Availability, error) {
@@ -258,6 +332,18 @@ func takeError(err *cError) error {
 	return errors.New(message)
 }
 
+// takeBuffer copies and frees buf.
+func takeBuffer(buf *cBuffer) string {
+	if buf.Data == nil {
+		return ""
+	}
+	s := fromBuffer(buf)
+	if fnBufferFree != nil {
+		fnBufferFree(unsafe.Pointer(buf))
+	}
+	return s
+}
+
 func fromBuffer(buf *cBuffer) string {
 	if buf == nil || buf.Data == nil || buf.Length <= 0 {
 		return ""
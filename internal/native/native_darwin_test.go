@@ -0,0 +1,50 @@
+//go:build darwin
+
+package native
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestShimExports calls every function registered from the embedded shim once. Loading the
+// package already fails when a symbol is missing; the calls below catch a shim built against an
+// older argument list, because only a matching ABI delivers the shim's error where Go reads it.
+func TestShimExports(t *testing.T) {
+	if _, err := CheckAvailability(); err != nil {
+		t.Fatalf("CheckAvailability: %v", err)
+	}
+
+	if ref, err := SessionCreate("Be brief."); err == nil {
+		SessionDestroy(ref)
+	}
+	if ref, err := SessionCreateWithTranscript("not json"); err == nil {
+		SessionDestroy(ref)
+		t.Fatal("SessionCreateWithTranscript accepted an invalid transcript")
+	}
+
+	token := CancelTokenCreate()
+	if token == nil {
+		t.Fatal("CancelTokenCreate returned nil")
+	}
+	defer CancelTokenDestroy(token)
+	CancelTokenCancel(token)
+
+	expectShimError := func(name string, err error) {
+		t.Helper()
+		if err == nil || !strings.Contains(err.Error(), "Invalid session handle") && !strings.Contains(err.Error(), "macOS 26") {
+			t.Errorf("%s with a nil session: expected the shim's error, got %v", name, err)
+		}
+	}
+
+	expectShimError("SessionPrewarm", SessionPrewarm(nil, "prefix"))
+
+	_, _, err := SessionRespond(nil, "hello", "", token)
+	expectShimError("SessionRespond", err)
+
+	_, _, err = SessionRespondStructured(nil, "hello", `{"type":"object"}`, "", token)
+	expectShimError("SessionRespondStructured", err)
+
+	_, err = SessionStream(nil, "hello", "", token, func(string, bool) {})
+	expectShimError("SessionStream", err)
+}
@@ -9,6 +9,8 @@ import (
 
 type SessionRef unsafe.Pointer
 
+type CancelToken unsafe.Pointer
+
 type Availability struct {
 	State  int32
 	Reason int32
@@ -20,18 +22,32 @@ func SessionCreate(string) (SessionRef, error) {
 	return nil, errors.New("fundament: macOS 26 is required")
 }
 
+func SessionCreateWithTranscript(string) (SessionRef, error) {
+	return nil, errors.New("fundament: macOS 26 is required")
+}
+
 func SessionDestroy(SessionRef) {}
 
-func SessionRespond(SessionRef, string, string) (string, error) {
-	return "", errors.New("fundament: macOS 26 is required")
+func SessionPrewarm(SessionRef, string) error {
+	return errors.New("fundament: macOS 26 is required")
 }
 
-func SessionRespondStructured(SessionRef, string, string, string) (string, error) {
-	return "", errors.New("fundament: macOS 26 is required")
+func CancelTokenCreate() CancelToken { return nil }
+
+func CancelTokenCancel(CancelToken) {}
+
+func CancelTokenDestroy(CancelToken) {}
+
+func SessionRespond(SessionRef, string, string, CancelToken) (string, string, error) {
+	return "", "", errors.New("fundament: macOS 26 is required")
 }
 
-func SessionStream(SessionRef, string, string, StreamCallback) error {
-	return errors.New("fundament: macOS 26 is required")
+func SessionRespondStructured(SessionRef, string, string, string, CancelToken) (string, string, error) {
+	return "", "", errors.New("fundament: macOS 26 is required")
+}
+
+func SessionStream(SessionRef, string, string, CancelToken, StreamCallback) (string, error) {
+	return "", errors.New("fundament: macOS 26 is required")
 }
 
 func CheckAvailability() (Availability, error) {
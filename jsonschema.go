@@ -0,0 +1,220 @@
+package fundament
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// schemaNode mirrors the shim's SchemaNode, the JSON shape of a DynamicGenerationSchema.
+type schemaNode struct {
+	Name            string           `json:"name,omitempty"`
+	Description     string           `json:"description,omitempty"`
+	Type            string           `json:"type,omitempty"`
+	Properties      []schemaProperty `json:"properties,omitempty"`
+	Items           *schemaNode      `json:"items,omitempty"`
+	MinimumElements *int             `json:"minimumElements,omitempty"`
+	MaximumElements *int             `json:"maximumElements,omitempty"`
+	AnyOf           []string         `json:"anyOf,omitempty"`
+	// Minimum and Maximum bound integer and number values, inclusive.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	// Pattern is a regular expression a string must match in full.
+	Pattern string `json:"pattern,omitempty"`
+	// Const fixes a string to a single value.
+	Const *string `json:"const,omitempty"`
+	// Ref points at an entry of the root's Definitions as "#/definitions/<name>".
+	Ref string `json:"$ref,omitempty"`
+	// Definitions holds the named objects and enums that Ref can point at. Only the root may
+	// declare them.
+	Definitions map[string]schemaNode `json:"definitions,omitempty"`
+}
+
+type schemaProperty struct {
+	Name   string     `json:"name"`
+	Schema schemaNode `json:"schema"`
+}
+
+func (s Schema) root() (schemaNode, error) {
+	if len(s.raw) == 0 {
+		return schemaNode{}, errors.New("fundament: schema must not be empty")
+	}
+	var node schemaNode
+	if err := json.Unmarshal(s.raw, &node); err != nil {
+		return schemaNode{}, fmt.Errorf("fundament: decode schema: %w", err)
+	}
+	return node, nil
+}
+
+// Name returns the root name declared by the schema, if any.
+func (s Schema) Name() string {
+	node, err := s.root()
+	if err != nil {
+		return ""
+	}
+	if name, ok := definitionName(node.Ref); ok {
+		return name
+	}
+	return node.Name
+}
+
+// JSONSchema translates the schema into standard JSON Schema for backends that accept it.
+// Objects list every property as required and forbid additional properties, matching the
+// guarantees of guided generation on the on-device model. Property order is preserved, and
+// definitions become $defs.
+func (s Schema) JSONSchema() ([]byte, error) {
+	node, err := s.root()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := jsonSchemaFor(node)
+	if err != nil {
+		return nil, err
+	}
+	if len(node.Definitions) > 0 {
+		var defs orderedObject
+		for _, name := range sortedDefinitions(node.Definitions) {
+			def, err := jsonSchemaFor(node.Definitions[name])
+			if err != nil {
+				return nil, fmt.Errorf("definition %q: %w", name, err)
+			}
+			defs.set(name, def)
+		}
+		obj.set("$defs", defs)
+	}
+	return json.Marshal(obj)
+}
+
+func jsonSchemaFor(node schemaNode) (orderedObject, error) {
+	var out orderedObject
+	if node.Ref != "" {
+		name, ok := definitionName(node.Ref)
+		if !ok {
+			return nil, fmt.Errorf("fundament: unsupported $ref %q", node.Ref)
+		}
+		out.set("$ref", "#/$defs/"+name)
+		if node.Description != "" {
+			out.set("description", node.Description)
+		}
+		return out, nil
+	}
+	if len(node.Properties) > 0 {
+		out.set("type", "object")
+		if node.Name != "" {
+			out.set("title", node.Name)
+		}
+		if node.Description != "" {
+			out.set("description", node.Description)
+		}
+		var props orderedObject
+		required := make([]string, 0, len(node.Properties))
+		for _, prop := range node.Properties {
+			child, err := jsonSchemaFor(prop.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("property %q: %w", prop.Name, err)
+			}
+			props.set(prop.Name, child)
+			required = append(required, prop.Name)
+		}
+		out.set("properties", props)
+		out.set("required", required)
+		out.set("additionalProperties", false)
+		return out, nil
+	}
+
+	switch node.Type {
+	case "array":
+		if node.Items == nil {
+			return nil, errors.New("fundament: array schema requires 'items'")
+		}
+		items, err := jsonSchemaFor(*node.Items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		out.set("type", "array")
+		if node.Description != "" {
+			out.set("description", node.Description)
+		}
+		out.set("items", items)
+		if node.MinimumElements != nil {
+			out.set("minItems", *node.MinimumElements)
+		}
+		if node.MaximumElements != nil {
+			out.set("maxItems", *node.MaximumElements)
+		}
+	case "string", "":
+		out.set("type", "string")
+		if node.Description != "" {
+			out.set("description", node.Description)
+		}
+		if node.AnyOf != nil {
+			out.set("enum", node.AnyOf)
+		}
+		if node.Pattern != "" {
+			// JSON Schema patterns match anywhere in the string; guides match the whole value.
+			out.set("pattern", "^(?:"+node.Pattern+")$")
+		}
+		if node.Const != nil {
+			out.set("const", *node.Const)
+		}
+	case "integer", "number", "double":
+		if node.Type == "integer" {
+			out.set("type", "integer")
+		} else {
+			out.set("type", "number")
+		}
+		if node.Description != "" {
+			out.set("description", node.Description)
+		}
+		if node.Minimum != nil {
+			out.set("minimum", *node.Minimum)
+		}
+		if node.Maximum != nil {
+			out.set("maximum", *node.Maximum)
+		}
+	case "boolean":
+		out.set("type", node.Type)
+		if node.Description != "" {
+			out.set("description", node.Description)
+		}
+	default:
+		return nil, fmt.Errorf("fundament: unsupported schema type %q", node.Type)
+	}
+	return out, nil
+}
+
+// orderedObject marshals as a JSON object whose keys keep insertion order.
+type orderedObject []orderedField
+
+type orderedField struct {
+	key   string
+	value any
+}
+
+func (o *orderedObject) set(key string, value any) {
+	*o = append(*o, orderedField{key: key, value: value})
+}
+
+func (o orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(field.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package fundament
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSchemaJSONSchema(t *testing.T) {
+	schema, err := SchemaFromRawJSON([]byte(`{
+		"name": "TravelPlan",
+		"description": "A short trip plan.",
+		"properties": [
+			{"name": "destination", "schema": {"type": "string", "description": "City and country."}},
+			{"name": "season", "schema": {"anyOf": ["spring", "autumn"]}},
+			{"name": "days", "schema": {"type": "integer"}},
+			{"name": "highlights", "schema": {
+				"type": "array",
+				"minimumElements": 2,
+				"maximumElements": 4,
+				"items": {"type": "string"}
+			}},
+			{"name": "budget", "schema": {"type": "boolean"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if schema.Name() != "TravelPlan" {
+		t.Fatalf("unexpected name %q", schema.Name())
+	}
+
+	got, err := schema.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema error: %v", err)
+	}
+	want := `{"type":"object","title":"TravelPlan","description":"A short trip plan.","properties":{` +
+		`"destination":{"type":"string","description":"City and country."},` +
+		`"season":{"type":"string","enum":["spring","autumn"]},` +
+		`"days":{"type":"integer"},` +
+		`"highlights":{"type":"array","items":{"type":"string"},"minItems":2,"maxItems":4},` +
+		`"budget":{"type":"boolean"}},` +
+		`"required":["destination","season","days","highlights","budget"],"additionalProperties":false}`
+	if string(got) != want {
+		t.Fatalf("unexpected JSON schema\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSchemaJSONSchemaGuides(t *testing.T) {
+	schema, err := SchemaFromRawJSON([]byte(`{"name":"Review","properties":[
+		{"name":"rating","schema":{"type":"integer","minimum":1,"maximum":5}},
+		{"name":"price","schema":{"type":"double","minimum":0}},
+		{"name":"sku","schema":{"type":"string","pattern":"[A-Z]+"}},
+		{"name":"kind","schema":{"const":"review"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	got, err := schema.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema error: %v", err)
+	}
+	want := `{"type":"object","title":"Review","properties":{` +
+		`"rating":{"type":"integer","minimum":1,"maximum":5},` +
+		`"price":{"type":"number","minimum":0},` +
+		`"sku":{"type":"string","pattern":"^(?:[A-Z]+)$"},` +
+		`"kind":{"type":"string","const":"review"}},` +
+		`"required":["rating","price","sku","kind"],"additionalProperties":false}`
+	if string(got) != want {
+		t.Fatalf("unexpected JSON schema\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSchemaJSONSchemaDefinitions(t *testing.T) {
+	schema, err := SchemaFromRawJSON([]byte(`{"name":"Thread","properties":[
+		{"name":"comments","schema":{"type":"array","items":{"$ref":"#/definitions/Comment"}}}
+	],"definitions":{
+		"Comment":{"name":"Comment","properties":[
+			{"name":"text","schema":{"type":"string"}},
+			{"name":"replies","schema":{"type":"array","items":{"$ref":"#/definitions/Comment"}}}
+		]}
+	}}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	got, err := schema.JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema error: %v", err)
+	}
+	want := `{"type":"object","title":"Thread","properties":{` +
+		`"comments":{"type":"array","items":{"$ref":"#/$defs/Comment"}}},` +
+		`"required":["comments"],"additionalProperties":false,` +
+		`"$defs":{"Comment":{"type":"object","title":"Comment","properties":{` +
+		`"text":{"type":"string"},` +
+		`"replies":{"type":"array","items":{"$ref":"#/$defs/Comment"}}},` +
+		`"required":["text","replies"],"additionalProperties":false}}}`
+	if string(got) != want {
+		t.Fatalf("unexpected JSON schema\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestSchemaJSONSchemaErrors(t *testing.T) {
+	cases := map[string]string{
+		"array without items": `{"type":"array"}`,
+		"unsupported type":    `{"type":"date"}`,
+		"nested unsupported":  `{"name":"X","properties":[{"name":"when","schema":{"type":"date"}}]}`,
+	}
+	for name, raw := range cases {
+		t.Run(name, func(t *testing.T) {
+			// SchemaFromRawJSON rejects these, so build the Schema directly.
+			schema := Schema{raw: json.RawMessage(raw)}
+			if _, err := schema.JSONSchema(); err == nil {
+				t.Fatal("expected translation error")
+			}
+		})
+	}
+
+	if _, err := (Schema{}).JSONSchema(); err == nil {
+		t.Fatal("expected error for empty schema")
+	}
+}
@@ -2,6 +2,9 @@ package fundament
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 )
 
 // GenerationOptions captures decoding-friendly options passed to the Swift shim.
@@ -11,6 +14,15 @@ type GenerationOptions struct {
 	TopK        *int
 	MaxTokens   *int
 	Seed        *uint64
+	// Sampling selects the sampling mode. It cannot be combined with TopP, TopK, or Seed,
+	// and TopP and TopK cannot be combined with each other.
+	Sampling *Sampling
+	// StopSequences and MaxCharacters end the response early; see WithStopSequences.
+	StopSequences []string
+	MaxCharacters *int
+	// SkipResponseValidation turns off the check of structured responses against their Schema;
+	// see WithoutResponseValidation.
+	SkipResponseValidation bool
 }
 
 // GenerationOption mutates GenerationOptions before encoding them for the shim.
@@ -51,36 +63,230 @@ func WithSeed(v uint64) GenerationOption {
 	}
 }
 
-func encodeGenerationOptions(overrides []GenerationOption) (GenerationOptions, string, error) {
+// Precise favours predictable, focused output, e.g. for extraction and classification.
+func Precise() GenerationOption {
+	return WithTemperature(0.2)
+}
+
+// Balanced is a middle ground suited to general chat.
+func Balanced() GenerationOption {
+	return WithTemperature(0.7)
+}
+
+// Creative favours varied output, e.g. for brainstorming and fiction.
+func Creative() GenerationOption {
+	return WithTemperature(1.2)
+}
+
+// ErrInvalidOption matches every *InvalidOptionError with errors.Is.
+var ErrInvalidOption = errors.New("fundament: invalid generation option")
+
+// InvalidOptionError reports a generation option outside its allowed range.
+type InvalidOptionError struct {
+	// Option is the field name as encoded for the shim, e.g. "topP".
+	Option string
+	Value  any
+	// Constraint describes the allowed range, e.g. "in (0, 1]".
+	Constraint string
+}
+
+func (e *InvalidOptionError) Error() string {
+	return fmt.Sprintf("fundament: %s %v must be %s", e.Option, e.Value, e.Constraint)
+}
+
+// Is reports whether target is ErrInvalidOption.
+func (e *InvalidOptionError) Is(target error) bool {
+	return target == ErrInvalidOption
+}
+
+// Validate checks every set option against the range the model accepts and returns an
+// *InvalidOptionError for the first one outside it.
+func (o GenerationOptions) Validate() error {
+	if v := o.Temperature; v != nil && (math.IsNaN(*v) || *v < 0 || *v > 2) {
+		return &InvalidOptionError{Option: "temperature", Value: *v, Constraint: "in [0, 2]"}
+	}
+	if v := o.TopP; v != nil && (math.IsNaN(*v) || *v <= 0 || *v > 1) {
+		return &InvalidOptionError{Option: "topP", Value: *v, Constraint: "in (0, 1]"}
+	}
+	if v := o.TopK; v != nil && *v <= 0 {
+		return &InvalidOptionError{Option: "topK", Value: *v, Constraint: "greater than 0"}
+	}
+	if v := o.MaxTokens; v != nil && *v <= 0 {
+		return &InvalidOptionError{Option: "maxTokens", Value: *v, Constraint: "greater than 0"}
+	}
+	for _, stop := range o.StopSequences {
+		if stop == "" {
+			return &InvalidOptionError{Option: "stop", Value: stop, Constraint: "a non-empty string"}
+		}
+	}
+	if v := o.MaxCharacters; v != nil && *v <= 0 {
+		return &InvalidOptionError{Option: "maxCharacters", Value: *v, Constraint: "greater than 0"}
+	}
+	if o.Sampling != nil {
+		switch {
+		case o.TopK != nil:
+			return &InvalidOptionError{Option: "topK", Value: *o.TopK, Constraint: "unset when Sampling is set"}
+		case o.TopP != nil:
+			return &InvalidOptionError{Option: "topP", Value: *o.TopP, Constraint: "unset when Sampling is set"}
+		case o.Seed != nil:
+			return &InvalidOptionError{Option: "seed", Value: *o.Seed, Constraint: "unset when Sampling is set; pass it to TopK or Nucleus"}
+		}
+		return o.Sampling.validate()
+	}
+	if o.TopK != nil && o.TopP != nil {
+		return &InvalidOptionError{Option: "topP", Value: *o.TopP, Constraint: "unset when topK is set"}
+	}
+	return nil
+}
+
+// EffectiveOptions returns the options a call with opts would send: the session's
+// SessionOptions.Defaults with opts applied on top. A sampling choice in opts replaces the
+// conflicting one in the defaults: a per-call Sampling drops the default TopK, TopP, and Seed,
+// a per-call TopK, TopP, or Seed drops the default Sampling, and a per-call TopK or TopP drops
+// the other default. The result is not validated.
+func (s *Session) EffectiveOptions(opts ...GenerationOption) GenerationOptions {
+	options := resolveGenerationOptions(s.defaults)
+	call := resolveGenerationOptions(opts)
+	if call.Sampling != nil {
+		options.TopK, options.TopP, options.Seed = nil, nil, nil
+	}
+	if call.TopK != nil || call.TopP != nil || call.Seed != nil {
+		options.Sampling = nil
+	}
+	if call.TopK != nil {
+		options.TopP = nil
+	}
+	if call.TopP != nil {
+		options.TopK = nil
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+// callOptions resolves and validates the options of a call.
+func (s *Session) callOptions(opts []GenerationOption) (GenerationOptions, error) {
+	options := s.EffectiveOptions(opts...)
+	if err := options.Validate(); err != nil {
+		return GenerationOptions{}, err
+	}
+	return options, nil
+}
+
+func resolveGenerationOptions(overrides []GenerationOption) GenerationOptions {
 	var base GenerationOptions
 	for _, opt := range overrides {
 		if opt != nil {
 			opt(&base)
 		}
 	}
+	return base
+}
+
+// Encode renders the options in the JSON form passed to the Swift shim.
+// Empty options encode to an empty string.
+func (o GenerationOptions) Encode() (string, error) {
+	return marshalGenerationOptions(o)
+}
+
+// DecodeGenerationOptions parses a blob produced by GenerationOptions.Encode.
+func DecodeGenerationOptions(blob string) (GenerationOptions, error) {
+	var opts GenerationOptions
+	if blob == "" {
+		return opts, nil
+	}
+	var payload struct {
+		Version     int              `json:"v"`
+		Temperature *float64         `json:"temperature"`
+		TopP        *float64         `json:"topP"`
+		TopK        *int             `json:"topK"`
+		MaxTokens   *int             `json:"maxTokens"`
+		Seed        *uint64          `json:"seed"`
+		Sampling    *samplingPayload `json:"sampling"`
+		Stop        []string         `json:"stop"`
+		MaxChars    *int             `json:"maxCharacters"`
+	}
+	if err := json.Unmarshal([]byte(blob), &payload); err != nil {
+		return opts, err
+	}
+	if payload.Version > optionsVersion {
+		return opts, fmt.Errorf("fundament: unsupported options version %d", payload.Version)
+	}
+	if payload.Sampling != nil {
+		sampling, err := payload.Sampling.sampling()
+		if err != nil {
+			return opts, err
+		}
+		opts.Sampling = &sampling
+	}
+	opts.Temperature = payload.Temperature
+	opts.TopP = payload.TopP
+	opts.TopK = payload.TopK
+	opts.MaxTokens = payload.MaxTokens
+	opts.Seed = payload.Seed
+	opts.StopSequences = payload.Stop
+	opts.MaxCharacters = payload.MaxChars
+	return opts, nil
+}
+
+// optionsVersion is the "v" field of encoded options. Bump it when the shape changes in a way
+// older shims would misread. Blobs without it predate versioning and decode as version 1.
+//
+// Version 1 is a JSON object with these optional fields, mirrored by GenerationOptionsPayload
+// in the Swift shim and pinned by the files in testdata/options:
+//
+//	v              int       contract version
+//	temperature    number
+//	topP           number    legacy; nucleus sampling threshold
+//	topK           int       legacy; top-k sampling
+//	maxTokens      int
+//	seed           uint64    legacy; seed for topP or topK
+//	sampling       object    {"mode": "greedy"|"topK"|"nucleus", "topK", "probabilityThreshold", "seed"}
+//	stop           []string  enforced by Go; the shim accepts and ignores it
+//	maxCharacters  int       enforced by Go; the shim accepts and ignores it
+//
+// The shim reports fields it cannot honour as warnings on the response rather than failing.
+const optionsVersion = 1
+
+// marshalGenerationOptions renders opts in the JSON shape the Swift shim decodes.
+// Empty options encode to an empty string.
+func marshalGenerationOptions(opts GenerationOptions) (string, error) {
 	payload := map[string]any{}
-	if base.Temperature != nil {
-		payload["temperature"] = *base.Temperature
+	if opts.Temperature != nil {
+		payload["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		payload["topP"] = *opts.TopP
+	}
+	if opts.TopK != nil {
+		payload["topK"] = *opts.TopK
+	}
+	if opts.MaxTokens != nil {
+		payload["maxTokens"] = *opts.MaxTokens
 	}
-	if base.TopP != nil {
-		payload["topP"] = *base.TopP
+	if opts.Seed != nil {
+		payload["seed"] = *opts.Seed
 	}
-	if base.TopK != nil {
-		payload["topK"] = *base.TopK
+	if opts.Sampling != nil {
+		payload["sampling"] = opts.Sampling.payload()
 	}
-	if base.MaxTokens != nil {
-		payload["maxTokens"] = *base.MaxTokens
+	if len(opts.StopSequences) > 0 {
+		payload["stop"] = opts.StopSequences
 	}
-	if base.Seed != nil {
-		payload["seed"] = *base.Seed
+	if opts.MaxCharacters != nil {
+		payload["maxCharacters"] = *opts.MaxCharacters
 	}
 
 	if len(payload) == 0 {
-		return base, "", nil
+		return "", nil
 	}
+	payload["v"] = optionsVersion
 	blob, err := json.Marshal(payload)
 	if err != nil {
-		return base, "", err
+		return "", err
 	}
-	return base, string(blob), nil
+	return string(blob), nil
 }
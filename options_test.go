@@ -1,12 +1,19 @@
 package fundament
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func TestEncodeGenerationOptions(t *testing.T) {
-	opts, payload, err := encodeGenerationOptions([]GenerationOption{
+	opts := resolveGenerationOptions([]GenerationOption{
 		WithTemperature(0.9),
 		WithTopP(0.2),
 		WithTopK(5),
@@ -14,12 +21,13 @@ func TestEncodeGenerationOptions(t *testing.T) {
 		WithSeed(123),
 		nil,
 	})
-	if err != nil {
-		t.Fatalf("encodeGenerationOptions error: %v", err)
-	}
 	if opts.Temperature == nil || *opts.Temperature != 0.9 {
 		t.Fatalf("unexpected temperature %+v", opts.Temperature)
 	}
+	payload, err := marshalGenerationOptions(opts)
+	if err != nil {
+		t.Fatalf("marshalGenerationOptions error: %v", err)
+	}
 	if payload == "" {
 		t.Fatal("expected JSON payload")
 	}
@@ -36,11 +44,215 @@ func TestEncodeGenerationOptions(t *testing.T) {
 		t.Fatalf("unexpected decoded payload %+v", decoded)
 	}
 
-	_, payload, err = encodeGenerationOptions(nil)
+	payload, err = marshalGenerationOptions(resolveGenerationOptions(nil))
 	if err != nil {
-		t.Fatalf("encodeGenerationOptions with nil overrides error: %v", err)
+		t.Fatalf("marshalGenerationOptions with nil overrides error: %v", err)
 	}
 	if payload != "" {
 		t.Fatalf("expected empty payload, got %q", payload)
 	}
 }
+
+func TestDecodeGenerationOptionsRoundTrip(t *testing.T) {
+	opts := resolveGenerationOptions([]GenerationOption{
+		WithTemperature(0.7),
+		WithTopK(40),
+		WithSeed(99),
+	})
+	blob, err := opts.Encode()
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	decoded, err := DecodeGenerationOptions(blob)
+	if err != nil {
+		t.Fatalf("DecodeGenerationOptions error: %v", err)
+	}
+	if *decoded.Temperature != 0.7 || *decoded.TopK != 40 || *decoded.Seed != 99 || decoded.TopP != nil || decoded.MaxTokens != nil {
+		t.Fatalf("unexpected decoded options %+v", decoded)
+	}
+
+	empty, err := DecodeGenerationOptions("")
+	if err != nil || !reflect.DeepEqual(empty, GenerationOptions{}) {
+		t.Fatalf("expected empty options, got %+v, %v", empty, err)
+	}
+	if _, err := DecodeGenerationOptions("{"); err == nil {
+		t.Fatal("expected error for malformed blob")
+	}
+}
+
+func TestGenerationOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		opt    GenerationOption
+		option string
+	}{
+		{"temperature negative", WithTemperature(-0.1), "temperature"},
+		{"temperature too high", WithTemperature(2.5), "temperature"},
+		{"topP zero", WithTopP(0), "topP"},
+		{"topP above one", WithTopP(1.1), "topP"},
+		{"topK zero", WithTopK(0), "topK"},
+		{"maxTokens negative", WithMaxTokens(-1), "maxTokens"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := resolveGenerationOptions([]GenerationOption{tc.opt}).Validate()
+			var invalid *InvalidOptionError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected *InvalidOptionError, got %v", err)
+			}
+			if invalid.Option != tc.option || !errors.Is(err, ErrInvalidOption) {
+				t.Fatalf("unexpected error %v", err)
+			}
+		})
+	}
+
+	valid := resolveGenerationOptions([]GenerationOption{WithTemperature(0), WithTopP(1), WithMaxTokens(1), Creative()})
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid options, got %v", err)
+	}
+}
+
+func TestSessionDefaultsPrecedence(t *testing.T) {
+	var got GenerationOptions
+	backend := &stubBackend{
+		respond: func(_ string, opts GenerationOptions) (string, error) {
+			got = opts
+			return "ok", nil
+		},
+	}
+	session, err := NewSession(SessionOptions{
+		Backend:  backend,
+		Defaults: []GenerationOption{Precise(), WithMaxTokens(100)},
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	eff := session.EffectiveOptions(WithMaxTokens(50), WithSeed(7))
+	if *eff.Temperature != 0.2 || *eff.MaxTokens != 50 || *eff.Seed != 7 {
+		t.Fatalf("unexpected effective options %+v", eff)
+	}
+
+	if _, err := session.Respond(context.Background(), "hi", WithTemperature(0.9)); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if *got.Temperature != 0.9 || *got.MaxTokens != 100 {
+		t.Fatalf("per-call options must override defaults field by field, got %+v", got)
+	}
+}
+
+func TestSessionDefaultsSamplingPrecedence(t *testing.T) {
+	backend := &stubBackend{respond: func(string, GenerationOptions) (string, error) { return "ok", nil }}
+	newSession := func(defaults ...GenerationOption) *Session {
+		t.Helper()
+		session, err := NewSession(SessionOptions{Backend: backend, Defaults: defaults})
+		if err != nil {
+			t.Fatalf("NewSession error: %v", err)
+		}
+		t.Cleanup(func() { session.Close() })
+		return session
+	}
+
+	topK := newSession(WithTopK(40), WithSeed(3))
+	if _, err := topK.Respond(context.Background(), "hi", WithSampling(Greedy())); err != nil {
+		t.Fatalf("per-call Sampling must replace the default TopK, got %v", err)
+	}
+	if eff := topK.EffectiveOptions(WithSampling(Greedy())); eff.TopK != nil || eff.Seed != nil || eff.Sampling.Mode != SamplingGreedy {
+		t.Fatalf("unexpected effective options %+v", eff)
+	}
+	if eff := topK.EffectiveOptions(WithTopP(0.9)); eff.TopK != nil || *eff.TopP != 0.9 || *eff.Seed != 3 {
+		t.Fatalf("per-call TopP must replace the default TopK, got %+v", eff)
+	}
+
+	greedy := newSession(WithSampling(Greedy()))
+	if _, err := greedy.Respond(context.Background(), "hi", WithTopK(10)); err != nil {
+		t.Fatalf("per-call TopK must replace the default Sampling, got %v", err)
+	}
+	if eff := greedy.EffectiveOptions(WithSeed(1)); eff.Sampling != nil || *eff.Seed != 1 {
+		t.Fatalf("per-call Seed must replace the default Sampling, got %+v", eff)
+	}
+}
+
+func TestInvalidOptionsNeverReachBackend(t *testing.T) {
+	called := false
+	backend := &stubBackend{
+		respond: func(string, GenerationOptions) (string, error) {
+			called = true
+			return "", nil
+		},
+	}
+	if _, err := NewSession(SessionOptions{Backend: backend, Defaults: []GenerationOption{WithTopK(0)}}); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected invalid defaults to fail NewSession, got %v", err)
+	}
+
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	if _, err := session.Respond(context.Background(), "hi", WithTopP(0)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption, got %v", err)
+	}
+	if _, err := session.RespondStream(context.Background(), "hi", WithMaxTokens(0)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption from RespondStream, got %v", err)
+	}
+	if called {
+		t.Fatal("invalid options must not reach the backend")
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// TestGenerationOptionsGolden pins the JSON contract shared with the Swift shim.
+func TestGenerationOptionsGolden(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []GenerationOption
+	}{
+		{"temperature", []GenerationOption{WithTemperature(0.5)}},
+		{"legacy", []GenerationOption{WithTemperature(0.7), WithTopK(40), WithMaxTokens(256), WithSeed(99)}},
+		{"greedy", []GenerationOption{WithSampling(Greedy()), WithMaxTokens(64)}},
+		{"topk", []GenerationOption{WithSampling(TopK(40, 7))}},
+		{"nucleus", []GenerationOption{WithTemperature(1.2), WithSampling(Nucleus(0.9, 7))}},
+		{"limits", []GenerationOption{WithStopSequences("\nUser:", "END"), WithMaxCharacters(280)}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := resolveGenerationOptions(tc.opts)
+			if err := opts.Validate(); err != nil {
+				t.Fatalf("golden options must be valid: %v", err)
+			}
+			blob, err := opts.Encode()
+			if err != nil {
+				t.Fatalf("Encode error: %v", err)
+			}
+			path := filepath.Join("testdata", "options", tc.name+".json")
+			if *update {
+				if err := os.WriteFile(path, []byte(blob+"\n"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("read golden file (run with -update to create it): %v", err)
+			}
+			want := strings.TrimSpace(string(golden))
+			if blob != want {
+				t.Fatalf("encoded options changed:\n got %s\nwant %s", blob, want)
+			}
+
+			decoded, err := DecodeGenerationOptions(want)
+			if err != nil {
+				t.Fatalf("DecodeGenerationOptions error: %v", err)
+			}
+			again, err := decoded.Encode()
+			if err != nil {
+				t.Fatalf("Encode error: %v", err)
+			}
+			if again != want {
+				t.Fatalf("round trip changed the blob:\n got %s\nwant %s", again, want)
+			}
+		})
+	}
+}
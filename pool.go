@@ -0,0 +1,213 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is returned by Pool.Acquire after Close.
+var ErrPoolClosed = errors.New("fundament: pool has been closed")
+
+// PoolOptions configure a Pool.
+type PoolOptions struct {
+	// Size is the number of sessions kept by the pool. It defaults to 4.
+	Size int
+	// Session is the template every pooled session is created from.
+	Session SessionOptions
+	// MaxHistory is the number of transcript entries a session may gain before it is replaced on
+	// release. The default of 0 hands out a fresh conversation for every Acquire, so one
+	// caller's prompts and responses never reach the next; this suits independent one-shot
+	// prompts. A negative MaxHistory keeps history without limit; pair it with
+	// SessionOptions.ContextPolicy so that long-lived sessions stay within the context window.
+	MaxHistory int
+}
+
+// PoolStats is a snapshot of a Pool's counters.
+type PoolStats struct {
+	InUse   int
+	Idle    int
+	Waiting int
+	// Created counts every session the pool has created.
+	Created int
+	// Recycled counts sessions replaced because of their history length or an error.
+	Recycled int
+}
+
+// Pool hands out pre-created sessions that share the same options, so concurrent requests
+// neither pay for session creation nor queue behind each other on one Session.
+type Pool struct {
+	opts PoolOptions
+	// slots holds one entry per session; a nil entry is created on demand by Acquire.
+	slots chan *Session
+	done  chan struct{}
+
+	mu       sync.Mutex
+	closed   bool
+	inUse    map[*Session]struct{}
+	baseline int // transcript length of a fresh session
+	waiting  int
+	created  int
+	recycled int
+}
+
+// NewPool creates a pool and its sessions. It fails if any session cannot be created.
+func NewPool(opts PoolOptions) (*Pool, error) {
+	if opts.Size <= 0 {
+		opts.Size = 4
+	}
+	p := &Pool{
+		opts:  opts,
+		slots: make(chan *Session, opts.Size),
+		done:  make(chan struct{}),
+		inUse: make(map[*Session]struct{}),
+	}
+	for i := 0; i < opts.Size; i++ {
+		s, err := p.newSession()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.slots <- s
+	}
+	return p, nil
+}
+
+func (p *Pool) newSession() (*Session, error) {
+	s, err := NewSession(p.opts.Session)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	p.created++
+	p.baseline = len(s.Transcript().Entries)
+	p.mu.Unlock()
+	return s, nil
+}
+
+// Acquire waits for an idle session. Callers must hand it back with Release.
+func (p *Pool) Acquire(ctx context.Context) (*Session, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	p.waiting++
+	p.mu.Unlock()
+
+	var (
+		s   *Session
+		got bool
+	)
+	select {
+	case s = <-p.slots:
+		got = true
+	case <-ctx.Done():
+	case <-p.done:
+	}
+	p.mu.Lock()
+	p.waiting--
+	p.mu.Unlock()
+	if !got {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrPoolClosed
+	}
+
+	if s == nil {
+		var err error
+		if s, err = p.newSession(); err != nil {
+			p.slots <- nil
+			return nil, err
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		s.Close()
+		return nil, ErrPoolClosed
+	}
+	p.inUse[s] = struct{}{}
+	return s, nil
+}
+
+// Release returns s to the pool. Pass the error of the last call made with s; sessions that
+// failed or grew beyond MaxHistory are closed and replaced. Sessions not acquired from p are ignored.
+func (p *Pool) Release(s *Session, err error) {
+	p.mu.Lock()
+	if _, ok := p.inUse[s]; !ok {
+		p.mu.Unlock()
+		return
+	}
+	delete(p.inUse, s)
+	if p.closed {
+		p.mu.Unlock()
+		s.Close()
+		return
+	}
+	recycle := err != nil || p.opts.MaxHistory >= 0 && len(s.Transcript().Entries)-p.baseline > p.opts.MaxHistory
+	if recycle {
+		p.recycled++
+	}
+	p.mu.Unlock()
+
+	if recycle {
+		s.Close()
+		// Replacements are created eagerly so the next Acquire does not pay for them;
+		// on failure the slot stays empty and Acquire retries.
+		s, _ = p.newSession()
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		if s != nil {
+			s.Close()
+		}
+		return
+	}
+	// The channel holds one entry per slot, so this never blocks.
+	p.slots <- s
+}
+
+// Stats returns the pool's current counters.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		InUse:    len(p.inUse),
+		Idle:     len(p.slots),
+		Waiting:  p.waiting,
+		Created:  p.created,
+		Recycled: p.recycled,
+	}
+}
+
+// Close closes idle sessions and wakes waiting callers. Sessions still in use are closed on Release.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	var errs []error
+	for {
+		select {
+		case s := <-p.slots:
+			if s != nil {
+				if err := s.Close(); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
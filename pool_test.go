@@ -0,0 +1,208 @@
+package fundament_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+func newTestPool(t *testing.T, model *fundamenttest.Model, opts fundament.PoolOptions) *fundament.Pool {
+	t.Helper()
+	opts.Session.Backend = model
+	pool, err := fundament.NewPool(opts)
+	if err != nil {
+		t.Fatalf("NewPool error: %v", err)
+	}
+	t.Cleanup(func() { pool.Close() })
+	return pool
+}
+
+func TestPoolServesConcurrentCallers(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("ok").Delay(5 * time.Millisecond)
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 3, Session: fundament.SessionOptions{Instructions: "one-shot"}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := pool.Acquire(context.Background())
+			if err != nil {
+				t.Errorf("Acquire error: %v", err)
+				return
+			}
+			_, err = s.Respond(context.Background(), "hi")
+			pool.Release(s, err)
+			if err != nil {
+				t.Errorf("Respond error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := pool.Stats()
+	if stats.InUse != 0 || stats.Idle != 3 || stats.Waiting != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	// Every use added history, so each release replaced the session.
+	if stats.Recycled != 10 || stats.Created != 13 {
+		t.Fatalf("unexpected counters %+v", stats)
+	}
+	if model.OpenSessions() != 3 {
+		t.Fatalf("expected 3 open sessions, got %d", model.OpenSessions())
+	}
+	for _, c := range model.Calls() {
+		if c.Instructions != "one-shot" {
+			t.Fatalf("session not created from the template: %+v", c)
+		}
+	}
+}
+
+func TestPoolKeepsHistoryUpToMaxHistory(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("ok")
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 1, MaxHistory: 4})
+
+	s1, _ := pool.Acquire(context.Background())
+	s1.Respond(context.Background(), "a")
+	pool.Release(s1, nil)
+	s2, _ := pool.Acquire(context.Background())
+	if s2 != s1 {
+		t.Fatal("session under the history threshold must be reused")
+	}
+	s2.Respond(context.Background(), "b")
+	s2.Respond(context.Background(), "c")
+	pool.Release(s2, nil)
+	s3, _ := pool.Acquire(context.Background())
+	defer pool.Release(s3, nil)
+	if s3 == s2 || len(s3.Transcript().Entries) != 0 {
+		t.Fatal("session beyond the history threshold must be replaced")
+	}
+	if got := pool.Stats().Recycled; got != 1 {
+		t.Fatalf("Recycled = %d, want 1", got)
+	}
+}
+
+func TestPoolIsolatesSequentialCallers(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("ok")
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 1})
+
+	first, _ := pool.Acquire(context.Background())
+	if _, err := first.Respond(context.Background(), "my account number is 42"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	pool.Release(first, nil)
+
+	second, _ := pool.Acquire(context.Background())
+	defer pool.Release(second, nil)
+	if entries := second.Transcript().Entries; len(entries) != 0 {
+		t.Fatalf("second caller sees the first caller's entries: %+v", entries)
+	}
+	if _, err := second.Respond(context.Background(), "hello"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if calls := model.Calls(); len(calls[1].Transcript.Entries) != 0 {
+		t.Fatalf("backend received the first caller's history: %+v", calls[1].Transcript)
+	}
+}
+
+func TestPoolKeepsHistoryWithNegativeMaxHistory(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("ok")
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 1, MaxHistory: -1})
+
+	s1, _ := pool.Acquire(context.Background())
+	for _, prompt := range []string{"a", "b", "c"} {
+		if _, err := s1.Respond(context.Background(), prompt); err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+	}
+	pool.Release(s1, nil)
+	s2, _ := pool.Acquire(context.Background())
+	defer pool.Release(s2, nil)
+	if s2 != s1 {
+		t.Fatal("with a negative MaxHistory a healthy session must be reused")
+	}
+	if stats := pool.Stats(); stats.Recycled != 0 || stats.Created != 1 {
+		t.Fatalf("unexpected counters %+v", stats)
+	}
+}
+
+func TestPoolReplacesFailedSessions(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Fail(errors.New("boom"))
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 1, MaxHistory: 100})
+
+	s1, _ := pool.Acquire(context.Background())
+	_, err := s1.Respond(context.Background(), "a")
+	pool.Release(s1, err)
+	s2, _ := pool.Acquire(context.Background())
+	defer pool.Release(s2, nil)
+	if s2 == s1 {
+		t.Fatal("failed session must be replaced")
+	}
+	if _, err := s1.Respond(context.Background(), "again"); err == nil {
+		t.Fatal("replaced session must be closed")
+	}
+}
+
+func TestPoolAcquireWaitsAndHonoursContext(t *testing.T) {
+	model := fundamenttest.NewModel()
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 1})
+
+	held, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+
+	got := make(chan *fundament.Session)
+	go func() {
+		s, _ := pool.Acquire(context.Background())
+		got <- s
+	}()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Waiting != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("waiter not registered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	pool.Release(held, nil)
+	s := <-got
+	if s == nil {
+		t.Fatal("waiter did not receive a session")
+	}
+	pool.Release(s, nil)
+}
+
+func TestPoolClose(t *testing.T) {
+	model := fundamenttest.NewModel()
+	pool := newTestPool(t, model, fundament.PoolOptions{Size: 2})
+
+	held, _ := pool.Acquire(context.Background())
+	if err := pool.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if model.OpenSessions() != 1 {
+		t.Fatalf("idle sessions must be closed, %d open", model.OpenSessions())
+	}
+	pool.Release(held, nil)
+	if model.OpenSessions() != 0 {
+		t.Fatal("sessions released after Close must be closed")
+	}
+	if _, err := pool.Acquire(context.Background()); !errors.Is(err, fundament.ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package fundament_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+func TestSessionPrewarm(t *testing.T) {
+	model := fundamenttest.NewModel()
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{Prewarm: true})
+
+	select {
+	case err, ok := <-session.Prewarmed():
+		if !ok || err != nil {
+			t.Fatalf("expected a nil result before close, got %v (ok=%v)", err, ok)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("prewarm did not complete")
+	}
+	if _, ok := <-session.Prewarmed(); ok {
+		t.Fatal("Prewarmed channel must be closed after the result")
+	}
+
+	if err := session.Prewarm(context.Background(), "Translate to German:"); err != nil {
+		t.Fatalf("Prewarm error: %v", err)
+	}
+	if got := model.Prewarms(); len(got) != 2 || got[0] != "" || got[1] != "Translate to German:" {
+		t.Fatalf("unexpected prewarms %q", got)
+	}
+
+	session.Close()
+	if err := session.Prewarm(context.Background(), ""); err == nil {
+		t.Fatal("expected error after Close")
+	}
+}
+
+func TestSessionPrewarmedClosedWithoutOption(t *testing.T) {
+	model := fundamenttest.NewModel()
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{})
+	if err := <-session.Prewarmed(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(model.Prewarms()) != 0 {
+		t.Fatal("prewarm must not run without the option")
+	}
+}
@@ -0,0 +1,99 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrSessionBusy is returned when a call arrives while another call on the same Session is in
+// progress and SessionOptions.OnBusy is BusyFail.
+var ErrSessionBusy = errors.New("fundament: session is busy responding")
+
+// BusyMode selects what a Session does with a call that arrives while another is in progress.
+type BusyMode int
+
+const (
+	// BusyQueue waits in first-in, first-out order until earlier calls have finished.
+	BusyQueue BusyMode = iota
+	// BusyFail returns ErrSessionBusy immediately.
+	BusyFail
+)
+
+// callQueue grants one call at a time in arrival order.
+type callQueue struct {
+	mu      sync.Mutex
+	busy    bool
+	waiters []chan struct{}
+}
+
+// enter reserves a place in line. The returned channel is closed once it is the caller's turn.
+func (q *callQueue) enter(mode BusyMode) (chan struct{}, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	turn := make(chan struct{})
+	if !q.busy {
+		q.busy = true
+		close(turn)
+		return turn, nil
+	}
+	if mode == BusyFail {
+		return nil, ErrSessionBusy
+	}
+	q.waiters = append(q.waiters, turn)
+	return turn, nil
+}
+
+// wait blocks until turn is granted. If ctx ends first the place in line is given up.
+func (q *callQueue) wait(ctx context.Context, turn chan struct{}) error {
+	select {
+	case <-turn:
+		return nil
+	case <-ctx.Done():
+	}
+	q.mu.Lock()
+	for i, w := range q.waiters {
+		if w == turn {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			q.mu.Unlock()
+			return ctx.Err()
+		}
+	}
+	q.mu.Unlock()
+	// The turn was granted while ctx ended; hand it to the next caller.
+	q.leave()
+	return ctx.Err()
+}
+
+// leave ends the current turn and wakes the next caller in line.
+func (q *callQueue) leave() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) > 0 {
+		next := q.waiters[0]
+		q.waiters = q.waiters[1:]
+		close(next)
+		return
+	}
+	q.busy = false
+}
+
+func (q *callQueue) active() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.busy
+}
+
+// IsResponding reports whether a call is currently in progress on the session.
+func (s *Session) IsResponding() bool {
+	return s.queue.active()
+}
+
+// acquire waits for the session's turn according to its BusyMode. Callers must call s.queue.leave.
+func (s *Session) acquire(ctx context.Context) error {
+	turn, err := s.queue.enter(s.onBusy)
+	if err != nil {
+		return err
+	}
+	return s.queue.wait(ctx, turn)
+}
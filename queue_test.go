@@ -0,0 +1,180 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingBackend returns a stub whose calls block until release is closed and records prompt order.
+func blockingBackend(release <-chan struct{}, order *[]string, mu *sync.Mutex) *stubBackend {
+	return &stubBackend{
+		respond: func(prompt string, _ GenerationOptions) (string, error) {
+			mu.Lock()
+			*order = append(*order, prompt)
+			mu.Unlock()
+			if prompt == "first" {
+				<-release
+			}
+			return prompt, nil
+		},
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func queued(s *Session) int {
+	s.queue.mu.Lock()
+	defer s.queue.mu.Unlock()
+	return len(s.queue.waiters)
+}
+
+func TestSessionSerializesCallsInFIFOOrder(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		order   []string
+		release = make(chan struct{})
+	)
+	session, err := NewSession(SessionOptions{Backend: blockingBackend(release, &order, &mu)})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	var wg sync.WaitGroup
+	call := func(prompt string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := session.Respond(context.Background(), prompt); err != nil {
+				t.Errorf("Respond(%q) error: %v", prompt, err)
+			}
+		}()
+	}
+
+	call("first")
+	waitFor(t, session.IsResponding)
+	for i, prompt := range []string{"second", "third", "fourth"} {
+		call(prompt)
+		want := i + 1
+		waitFor(t, func() bool { return queued(session) == want })
+	}
+
+	mu.Lock()
+	if len(order) != 1 {
+		mu.Unlock()
+		t.Fatalf("queued calls must not reach the backend, saw %q", order)
+	}
+	mu.Unlock()
+
+	close(release)
+	wg.Wait()
+	if session.IsResponding() {
+		t.Fatal("session still reports a call in progress")
+	}
+	want := []string{"first", "second", "third", "fourth"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("calls served out of order: %q", order)
+		}
+	}
+}
+
+func TestSessionQueuedCallHonoursContext(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		order   []string
+		release = make(chan struct{})
+	)
+	session, err := NewSession(SessionOptions{Backend: blockingBackend(release, &order, &mu)})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		session.Respond(context.Background(), "first")
+	}()
+	waitFor(t, session.IsResponding)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := session.Respond(ctx, "cancelled")
+		errc <- err
+	}()
+	waitFor(t, func() bool { return queued(session) == 1 })
+
+	ch, err := session.RespondStream(ctx, "cancelled stream")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	waitFor(t, func() bool { return queued(session) == 2 })
+
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	for range ch {
+	}
+	if n := queued(session); n != 0 {
+		t.Fatalf("cancelled callers must leave the queue, %d left", n)
+	}
+
+	close(release)
+	<-done
+	if _, err := session.Respond(context.Background(), "after"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(order) != 2 || order[1] != "after" {
+		t.Fatalf("cancelled calls reached the backend: %q", order)
+	}
+}
+
+func TestSessionBusyFail(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		order   []string
+		release = make(chan struct{})
+	)
+	session, err := NewSession(SessionOptions{Backend: blockingBackend(release, &order, &mu), OnBusy: BusyFail})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		session.Respond(context.Background(), "first")
+	}()
+	waitFor(t, session.IsResponding)
+
+	if _, err := session.Respond(context.Background(), "second"); !errors.Is(err, ErrSessionBusy) {
+		t.Fatalf("expected ErrSessionBusy, got %v", err)
+	}
+	if _, err := session.RespondStream(context.Background(), "stream"); !errors.Is(err, ErrSessionBusy) {
+		t.Fatalf("expected ErrSessionBusy from RespondStream, got %v", err)
+	}
+
+	close(release)
+	<-done
+	if _, err := session.Respond(context.Background(), "third"); err != nil {
+		t.Fatalf("Respond after the busy call finished: %v", err)
+	}
+}
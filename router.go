@@ -0,0 +1,314 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Route is a named Backend considered by a Router.
+type Route struct {
+	Name    string
+	Backend Backend
+}
+
+// FallbackCause classifies why a Router considers leaving a route.
+type FallbackCause int
+
+const (
+	// FallbackUnavailable means the availability check failed or reported the route as not ready.
+	FallbackUnavailable FallbackCause = iota
+	// FallbackSessionError means the route could not create a session.
+	FallbackSessionError
+	// FallbackCallError means a call failed before the conversation was pinned to the route.
+	FallbackCallError
+)
+
+// FallbackEvent describes why a Router is about to leave a route.
+type FallbackEvent struct {
+	Route string
+	Cause FallbackCause
+	// Availability is the status reported by the route. It is only meaningful when Err is nil.
+	Availability Availability
+	// Err is the availability check, session creation, or call error, if any.
+	Err error
+}
+
+// FallbackPolicy decides whether a Router may move on to the next route.
+type FallbackPolicy func(FallbackEvent) bool
+
+// DefaultFallbackPolicy falls back from unavailable routes and routes that cannot create a session,
+// and from failed calls when the error is retryable.
+func DefaultFallbackPolicy(ev FallbackEvent) bool {
+	if ev.Cause == FallbackCallError {
+		return IsRetryable(ev.Err)
+	}
+	return true
+}
+
+// IsRetryable reports whether err is a transient failure worth retrying elsewhere.
+// Errors implementing Retryable() bool decide for themselves; network errors are retryable;
+// context cancellation and deadlines are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var r interface{ Retryable() bool }
+	if errors.As(err, &r) {
+		return r.Retryable()
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// RouterOptions configure a Router.
+type RouterOptions struct {
+	// Policy defaults to DefaultFallbackPolicy.
+	Policy FallbackPolicy
+}
+
+// Router is a Backend that prefers its first route, typically NativeBackend, and falls back to the
+// following routes when a route is unavailable or a call fails.
+//
+// Routing is sticky per session: once a route has served a response, the conversation stays there so its
+// history is never split across providers, and later errors are returned to the caller. The route that
+// served a response is reported in the Backend field of Response, StructuredResponse, and StreamChunk.
+type Router struct {
+	routes []Route
+	policy FallbackPolicy
+}
+
+// NewRouter returns a Router trying routes in order.
+func NewRouter(opts RouterOptions, routes ...Route) *Router {
+	policy := opts.Policy
+	if policy == nil {
+		policy = DefaultFallbackPolicy
+	}
+	return &Router{
+		routes: append([]Route(nil), routes...),
+		policy: policy,
+	}
+}
+
+// CheckAvailability implements Backend. The router is ready when any route is.
+func (r *Router) CheckAvailability() (Availability, error) {
+	var (
+		last    Availability
+		lastErr error
+	)
+	for _, route := range r.routes {
+		a, err := route.Backend.CheckAvailability()
+		if err == nil && a.State == AvailabilityReady {
+			return a, nil
+		}
+		last, lastErr = a, err
+	}
+	if lastErr != nil {
+		return Availability{}, lastErr
+	}
+	if len(r.routes) == 0 {
+		return Availability{State: AvailabilityUnavailable, Reason: AvailabilityReasonUnknown}, nil
+	}
+	return last, nil
+}
+
+// NewSession implements Backend by opening a session on the first usable route.
+func (r *Router) NewSession(instructions string) (BackendSession, error) {
+	s := &routerSession{router: r, instructions: instructions, index: -1}
+	if err := s.advance(nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewSessionFromTranscript implements TranscriptBackend. Routes whose backend cannot restore
+// a transcript are treated like routes that fail to create a session.
+func (r *Router) NewSessionFromTranscript(t Transcript) (BackendSession, error) {
+	s := &routerSession{router: r, instructions: t.Instructions(), transcript: &t, index: -1}
+	if err := s.advance(nil); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type routerSession struct {
+	router       *Router
+	instructions string
+	transcript   *Transcript
+
+	mu       sync.Mutex
+	index    int
+	current  BackendSession
+	attempts []string
+	sticky   bool
+	closed   bool
+}
+
+// advance closes the current route and opens a session on the next usable one.
+// failure, if set, is the call error that triggered the move. Callers hold s.mu or own s exclusively.
+func (s *routerSession) advance(failure error) error {
+	if s.current != nil {
+		s.current.Close()
+		s.current = nil
+	}
+	for s.index+1 < len(s.router.routes) {
+		s.index++
+		route := s.router.routes[s.index]
+		a, err := route.Backend.CheckAvailability()
+		if err != nil || a.State != AvailabilityReady {
+			s.attempts = append(s.attempts, describeAttempt(route.Name, a, err))
+			if s.router.policy(FallbackEvent{Route: route.Name, Cause: FallbackUnavailable, Availability: a, Err: err}) {
+				continue
+			}
+			if err == nil {
+				err = fmt.Errorf("fundament: route %q is %v", route.Name, a)
+			}
+			return err
+		}
+		bs, err := s.open(route.Backend)
+		if err != nil {
+			s.attempts = append(s.attempts, describeAttempt(route.Name, a, err))
+			if s.router.policy(FallbackEvent{Route: route.Name, Cause: FallbackSessionError, Availability: a, Err: err}) {
+				continue
+			}
+			return err
+		}
+		s.current = bs
+		return nil
+	}
+	msg := "fundament: no route available"
+	if len(s.attempts) > 0 {
+		msg += " (" + strings.Join(s.attempts, "; ") + ")"
+	}
+	if failure != nil {
+		return fmt.Errorf("%s: %w", msg, failure)
+	}
+	return errors.New(msg)
+}
+
+func (s *routerSession) open(b Backend) (BackendSession, error) {
+	if s.transcript == nil {
+		return b.NewSession(s.instructions)
+	}
+	tb, ok := b.(TranscriptBackend)
+	if !ok {
+		return nil, errTranscriptUnsupported
+	}
+	return tb.NewSessionFromTranscript(s.transcript.clone())
+}
+
+func describeAttempt(name string, a Availability, err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s: %v", name, err)
+	}
+	return fmt.Sprintf("%s: %v", name, a)
+}
+
+// call runs fn against the current route, moving on to later routes while the
+// conversation has not been pinned and the policy allows it.
+func (s *routerSession) call(fn func(BackendSession) (delivered bool, err error)) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		if s.closed {
+			return "", ErrSessionClosed
+		}
+		if s.current == nil {
+			return "", errors.New("fundament: no route available")
+		}
+		route := s.router.routes[s.index].Name
+		delivered, err := fn(s.current)
+		if err == nil {
+			s.sticky = true
+			return route, nil
+		}
+		if s.sticky || delivered {
+			return route, err
+		}
+		s.attempts = append(s.attempts, describeAttempt(route, Availability{}, err))
+		if !s.router.policy(FallbackEvent{Route: route, Cause: FallbackCallError, Err: err}) {
+			return route, err
+		}
+		if advanceErr := s.advance(err); advanceErr != nil {
+			return route, advanceErr
+		}
+	}
+}
+
+func (s *routerSession) Respond(ctx context.Context, prompt string, opts GenerationOptions) (Response, error) {
+	var resp Response
+	route, err := s.call(func(bs BackendSession) (bool, error) {
+		var err error
+		resp, err = bs.Respond(ctx, prompt, opts)
+		return false, err
+	})
+	if err != nil {
+		return Response{}, err
+	}
+	resp.Backend = route
+	return resp, nil
+}
+
+func (s *routerSession) RespondStructured(ctx context.Context, prompt string, schema Schema, opts GenerationOptions) (StructuredResponse, error) {
+	var resp StructuredResponse
+	route, err := s.call(func(bs BackendSession) (bool, error) {
+		var err error
+		resp, err = bs.RespondStructured(ctx, prompt, schema, opts)
+		return false, err
+	})
+	if err != nil {
+		return StructuredResponse{}, err
+	}
+	resp.Backend = route
+	return resp, nil
+}
+
+func (s *routerSession) RespondStream(ctx context.Context, prompt string, opts GenerationOptions, fn func(StreamChunk)) error {
+	if fn == nil {
+		return errors.New("fundament: stream callback must not be nil")
+	}
+	_, err := s.call(func(bs BackendSession) (bool, error) {
+		// Chunks already handed to the caller cannot be taken back, so only
+		// failures before the first chunk may fall back.
+		delivered := false
+		route := s.router.routes[s.index].Name
+		err := bs.RespondStream(ctx, prompt, opts, func(chunk StreamChunk) {
+			delivered = true
+			chunk.Backend = route
+			fn(chunk)
+		})
+		return delivered, err
+	})
+	return err
+}
+
+// Prewarm forwards to the current route when its session implements Prewarmer. The route is
+// read under s.mu but prewarmed outside it, so a slow prewarm does not hold up calls.
+func (s *routerSession) Prewarm(ctx context.Context, promptPrefix string) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrSessionClosed
+	}
+	current := s.current
+	s.mu.Unlock()
+	if p, ok := current.(Prewarmer); ok {
+		return p.Prewarm(ctx, promptPrefix)
+	}
+	return nil
+}
+
+func (s *routerSession) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.current == nil {
+		return nil
+	}
+	err := s.current.Close()
+	s.current = nil
+	return err
+}
@@ -0,0 +1,248 @@
+package fundament_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+// transientError is a retryable failure as reported by HTTP backends.
+type transientError struct{}
+
+func (transientError) Error() string   { return "temporarily overloaded" }
+func (transientError) Retryable() bool { return true }
+
+func newRouterSession(t *testing.T, opts fundament.RouterOptions, routes ...fundament.Route) *fundament.Session {
+	t.Helper()
+	session, err := fundament.NewSession(fundament.SessionOptions{
+		Instructions: "route me",
+		Backend:      fundament.NewRouter(opts, routes...),
+	})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+func TestRouterFallsBackWhenPrimaryUnavailable(t *testing.T) {
+	primary := fundamenttest.NewModel()
+	primary.SetAvailability(fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonModelNotReady}, nil)
+	secondary := fundamenttest.NewModel()
+	secondary.On(fundamenttest.Any()).Reply("from secondary")
+
+	var events []fundament.FallbackEvent
+	session := newRouterSession(t, fundament.RouterOptions{
+		Policy: func(ev fundament.FallbackEvent) bool {
+			events = append(events, ev)
+			return fundament.DefaultFallbackPolicy(ev)
+		},
+	},
+		fundament.Route{Name: "on-device", Backend: primary},
+		fundament.Route{Name: "ollama", Backend: secondary},
+	)
+
+	resp, err := session.Respond(context.Background(), "hello")
+	if err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if resp.Text != "from secondary" || resp.Backend != "ollama" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(events) != 1 || events[0].Route != "on-device" || events[0].Cause != fundament.FallbackUnavailable || events[0].Availability.Reason != fundament.AvailabilityReasonModelNotReady {
+		t.Fatalf("unexpected fallback events %+v", events)
+	}
+	if primary.OpenSessions() != 0 {
+		t.Fatal("unavailable route must not get a session")
+	}
+	if calls := secondary.Calls(); len(calls) != 1 || calls[0].Instructions != "route me" {
+		t.Fatalf("instructions not forwarded: %+v", calls)
+	}
+}
+
+func TestRouterFallsBackOnRetryableErrorOnlyBeforeFirstResponse(t *testing.T) {
+	primary := fundamenttest.NewModel()
+	primary.On(fundamenttest.Exact("first")).Fail(transientError{}).Times(1)
+	primary.On(fundamenttest.Any()).Reply("from primary")
+	secondary := fundamenttest.NewModel()
+	secondary.On(fundamenttest.Any()).Reply("from secondary")
+
+	routes := []fundament.Route{
+		{Name: "on-device", Backend: primary},
+		{Name: "remote", Backend: secondary},
+	}
+
+	// A fresh conversation moves to the next route.
+	session := newRouterSession(t, fundament.RouterOptions{}, routes...)
+	resp, err := session.Respond(context.Background(), "first")
+	if err != nil || resp.Backend != "remote" {
+		t.Fatalf("expected fallback to remote, got %+v, %v", resp, err)
+	}
+	if primary.OpenSessions() != 0 {
+		t.Fatal("abandoned route session must be closed")
+	}
+
+	// Once a route has answered, the conversation sticks to it.
+	primary.Reset()
+	primary.On(fundamenttest.Exact("second")).Fail(transientError{})
+	primary.On(fundamenttest.Any()).Reply("from primary")
+
+	sticky := newRouterSession(t, fundament.RouterOptions{}, routes...)
+	resp, err = sticky.Respond(context.Background(), "warm up")
+	if err != nil || resp.Backend != "on-device" {
+		t.Fatalf("expected primary, got %+v, %v", resp, err)
+	}
+	if _, err := sticky.Respond(context.Background(), "second"); !errors.As(err, new(transientError)) {
+		t.Fatalf("sticky session must surface the error, got %v", err)
+	}
+	if n := len(secondary.Calls()); n != 1 {
+		t.Fatalf("secondary must not be consulted after pinning, saw %d calls", n)
+	}
+}
+
+func TestRouterDoesNotFallBackOnPermanentError(t *testing.T) {
+	permanent := errors.New("guardrail violation")
+	primary := fundamenttest.NewModel()
+	primary.On(fundamenttest.Any()).Fail(permanent)
+	secondary := fundamenttest.NewModel()
+	secondary.On(fundamenttest.Any()).Reply("unused")
+
+	session := newRouterSession(t, fundament.RouterOptions{},
+		fundament.Route{Name: "on-device", Backend: primary},
+		fundament.Route{Name: "remote", Backend: secondary},
+	)
+	if _, err := session.Respond(context.Background(), "hi"); !errors.Is(err, permanent) {
+		t.Fatalf("expected permanent error, got %v", err)
+	}
+	if len(secondary.Calls()) != 0 {
+		t.Fatal("permanent errors must not fall back")
+	}
+}
+
+func TestRouterStreamFallbackReportsBackend(t *testing.T) {
+	primary := fundamenttest.NewModel()
+	primary.On(fundamenttest.Any()).Fail(transientError{})
+	secondary := fundamenttest.NewModel()
+	secondary.On(fundamenttest.Any()).Stream("a", "b")
+
+	session := newRouterSession(t, fundament.RouterOptions{},
+		fundament.Route{Name: "on-device", Backend: primary},
+		fundament.Route{Name: "remote", Backend: secondary},
+	)
+	ch, err := session.RespondStream(context.Background(), "stream")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var got []fundament.StreamChunk
+	for chunk := range ch {
+		got = append(got, chunk)
+	}
+	if len(got) != 2 || got[0].Backend != "remote" || got[1].Text != "b" || !got[1].Final {
+		t.Fatalf("unexpected chunks %+v", got)
+	}
+}
+
+func TestRouterPolicyVeto(t *testing.T) {
+	primary := fundamenttest.NewModel()
+	primary.SetAvailability(fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonAppleIntelligenceDisabled}, nil)
+	secondary := fundamenttest.NewModel()
+
+	router := fundament.NewRouter(fundament.RouterOptions{
+		Policy: func(fundament.FallbackEvent) bool { return false },
+	},
+		fundament.Route{Name: "on-device", Backend: primary},
+		fundament.Route{Name: "remote", Backend: secondary},
+	)
+	if _, err := fundament.NewSession(fundament.SessionOptions{Backend: router}); err == nil {
+		t.Fatal("expected error when the policy refuses to fall back")
+	}
+	if secondary.OpenSessions() != 0 {
+		t.Fatal("vetoed fallback must not open a session")
+	}
+
+	a, err := router.CheckAvailability()
+	if err != nil || a.State != fundament.AvailabilityReady {
+		t.Fatalf("router should be ready when any route is, got %+v, %v", a, err)
+	}
+}
+
+func TestRouterAllRoutesExhausted(t *testing.T) {
+	down := fundamenttest.NewModel()
+	down.SetAvailability(fundament.Availability{State: fundament.AvailabilityUnavailable, Reason: fundament.AvailabilityReasonDeviceNotEligible}, nil)
+	broken := fundamenttest.NewModel()
+	broken.SetAvailability(fundament.Availability{}, errors.New("connection refused"))
+	healthy := fundamenttest.NewModel()
+	healthy.On(fundamenttest.Any()).Reply("ok")
+
+	// Availability check failures fall back like unavailable routes.
+	session := newRouterSession(t, fundament.RouterOptions{},
+		fundament.Route{Name: "on-device", Backend: down},
+		fundament.Route{Name: "remote", Backend: broken},
+		fundament.Route{Name: "last resort", Backend: healthy},
+	)
+	if resp, err := session.Respond(context.Background(), "hi"); err != nil || resp.Backend != "last resort" {
+		t.Fatalf("expected last resort route, got %+v, %v", resp, err)
+	}
+
+	_, err := fundament.NewSession(fundament.SessionOptions{
+		Backend: fundament.NewRouter(fundament.RouterOptions{},
+			fundament.Route{Name: "on-device", Backend: down},
+			fundament.Route{Name: "remote", Backend: broken},
+		),
+	})
+	if err == nil {
+		t.Fatal("expected error when no route is available")
+	}
+}
+
+// blockingPrewarmBackend hands out sessions whose Prewarm blocks until its context ends.
+type blockingPrewarmBackend struct {
+	*fundamenttest.Model
+	started chan struct{}
+}
+
+func (b blockingPrewarmBackend) NewSession(instructions string) (fundament.BackendSession, error) {
+	bs, err := b.Model.NewSession(instructions)
+	return blockingPrewarmSession{BackendSession: bs, started: b.started}, err
+}
+
+type blockingPrewarmSession struct {
+	fundament.BackendSession
+	started chan struct{}
+}
+
+func (s blockingPrewarmSession) Prewarm(ctx context.Context, _ string) error {
+	close(s.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRouterPrewarmDoesNotBlockCalls(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("ready")
+	backend := blockingPrewarmBackend{Model: model, started: make(chan struct{})}
+	session := newRouterSession(t, fundament.RouterOptions{}, fundament.Route{Name: "slow", Backend: backend})
+
+	prewarmCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go session.Prewarm(prewarmCtx, "prefix")
+	<-backend.started
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := session.Respond(context.Background(), "hello")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Respond error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Respond must not wait for a prewarm in progress")
+	}
+}
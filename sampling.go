@@ -0,0 +1,157 @@
+package fundament
+
+import (
+	"fmt"
+	"math"
+)
+
+// SamplingMode selects how the model picks the next token.
+type SamplingMode int
+
+const (
+	// SamplingGreedy always picks the most likely token, so output is deterministic.
+	SamplingGreedy SamplingMode = iota + 1
+	// SamplingTopK samples from the K most likely tokens.
+	SamplingTopK
+	// SamplingNucleus samples from the smallest set of tokens whose probabilities add up to
+	// Threshold.
+	SamplingNucleus
+)
+
+func (m SamplingMode) String() string {
+	switch m {
+	case SamplingGreedy:
+		return "greedy"
+	case SamplingTopK:
+		return "topK"
+	case SamplingNucleus:
+		return "nucleus"
+	default:
+		return fmt.Sprintf("SamplingMode(%d)", int(m))
+	}
+}
+
+// Sampling mirrors FoundationModels' GenerationOptions.SamplingMode. Build it with Greedy,
+// TopK, or Nucleus and pass it with WithSampling. It replaces the TopK, TopP, and Seed options,
+// which cannot be combined with it.
+type Sampling struct {
+	Mode SamplingMode
+	// K is the token count for SamplingTopK.
+	K int
+	// Threshold is the probability mass for SamplingNucleus, in (0, 1].
+	Threshold float64
+	// Seed makes random sampling reproducible. It is ignored by SamplingGreedy.
+	Seed *uint64
+}
+
+// Greedy selects deterministic sampling.
+func Greedy() Sampling {
+	return Sampling{Mode: SamplingGreedy}
+}
+
+// TopK selects random sampling among the k most likely tokens.
+func TopK(k int, seed uint64) Sampling {
+	return Sampling{Mode: SamplingTopK, K: k, Seed: &seed}
+}
+
+// Nucleus selects random sampling among the most likely tokens whose probabilities add up to p.
+func Nucleus(p float64, seed uint64) Sampling {
+	return Sampling{Mode: SamplingNucleus, Threshold: p, Seed: &seed}
+}
+
+// WithSampling sets the sampling mode.
+func WithSampling(s Sampling) GenerationOption {
+	return func(opts *GenerationOptions) {
+		opts.Sampling = &s
+	}
+}
+
+// validate checks the parameters of the mode.
+func (s Sampling) validate() error {
+	switch s.Mode {
+	case SamplingGreedy:
+		return nil
+	case SamplingTopK:
+		if s.K <= 0 {
+			return &InvalidOptionError{Option: "sampling.topK", Value: s.K, Constraint: "greater than 0"}
+		}
+		return nil
+	case SamplingNucleus:
+		if math.IsNaN(s.Threshold) || s.Threshold <= 0 || s.Threshold > 1 {
+			return &InvalidOptionError{Option: "sampling.probabilityThreshold", Value: s.Threshold, Constraint: "in (0, 1]"}
+		}
+		return nil
+	default:
+		return &InvalidOptionError{Option: "sampling.mode", Value: s.Mode, Constraint: "Greedy, TopK, or Nucleus"}
+	}
+}
+
+// samplingPayload is the JSON form of Sampling shared with the Swift shim.
+type samplingPayload struct {
+	Mode                 string   `json:"mode"`
+	TopK                 *int     `json:"topK,omitempty"`
+	ProbabilityThreshold *float64 `json:"probabilityThreshold,omitempty"`
+	Seed                 *uint64  `json:"seed,omitempty"`
+}
+
+func (s Sampling) payload() samplingPayload {
+	p := samplingPayload{Mode: s.Mode.String()}
+	switch s.Mode {
+	case SamplingTopK:
+		k := s.K
+		p.TopK = &k
+		p.Seed = s.Seed
+	case SamplingNucleus:
+		t := s.Threshold
+		p.ProbabilityThreshold = &t
+		p.Seed = s.Seed
+	}
+	return p
+}
+
+func (p samplingPayload) sampling() (Sampling, error) {
+	var s Sampling
+	switch p.Mode {
+	case "greedy":
+		s.Mode = SamplingGreedy
+	case "topK":
+		s.Mode = SamplingTopK
+		if p.TopK != nil {
+			s.K = *p.TopK
+		}
+	case "nucleus":
+		s.Mode = SamplingNucleus
+		if p.ProbabilityThreshold != nil {
+			s.Threshold = *p.ProbabilityThreshold
+		}
+	default:
+		return s, fmt.Errorf("fundament: unknown sampling mode %q", p.Mode)
+	}
+	if s.Mode != SamplingGreedy {
+		s.Seed = p.Seed
+	}
+	return s, nil
+}
+
+// Flatten expresses Sampling through the TopK, TopP, Seed, and Temperature fields, for backends
+// whose APIs take independent knobs. Greedy becomes a temperature of 0. Options without
+// Sampling are returned unchanged.
+func (o GenerationOptions) Flatten() GenerationOptions {
+	if o.Sampling == nil {
+		return o
+	}
+	s := *o.Sampling
+	o.Sampling = nil
+	switch s.Mode {
+	case SamplingGreedy:
+		zero := 0.0
+		o.Temperature = &zero
+	case SamplingTopK:
+		o.TopK = &s.K
+		o.Seed = s.Seed
+	case SamplingNucleus:
+		o.TopP = &s.Threshold
+		o.Seed = s.Seed
+	}
+	return o
+}
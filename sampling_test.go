@@ -0,0 +1,93 @@
+package fundament
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSamplingEncodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		sampling Sampling
+		blob     string
+	}{
+		{"greedy", Greedy(), `{"sampling":{"mode":"greedy"},"v":1}`},
+		{"topK", TopK(40, 7), `{"sampling":{"mode":"topK","topK":40,"seed":7},"v":1}`},
+		{"nucleus", Nucleus(0.9, 7), `{"sampling":{"mode":"nucleus","probabilityThreshold":0.9,"seed":7},"v":1}`},
+		{"unseeded", Sampling{Mode: SamplingTopK, K: 3}, `{"sampling":{"mode":"topK","topK":3},"v":1}`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := resolveGenerationOptions([]GenerationOption{WithSampling(tc.sampling)})
+			if err := opts.Validate(); err != nil {
+				t.Fatalf("Validate error: %v", err)
+			}
+			blob, err := opts.Encode()
+			if err != nil {
+				t.Fatalf("Encode error: %v", err)
+			}
+			if blob != tc.blob {
+				t.Fatalf("blob = %s, want %s", blob, tc.blob)
+			}
+			decoded, err := DecodeGenerationOptions(blob)
+			if err != nil {
+				t.Fatalf("DecodeGenerationOptions error: %v", err)
+			}
+			got := decoded.Sampling
+			if got == nil || got.Mode != tc.sampling.Mode || got.K != tc.sampling.K || got.Threshold != tc.sampling.Threshold ||
+				(got.Seed == nil) != (tc.sampling.Seed == nil) || (got.Seed != nil && *got.Seed != *tc.sampling.Seed) {
+				t.Fatalf("decoded sampling %+v, want %+v", got, tc.sampling)
+			}
+		})
+	}
+}
+
+func TestDecodeGenerationOptionsRejectsNewerVersion(t *testing.T) {
+	if _, err := DecodeGenerationOptions(`{"v":2,"temperature":0.5}`); err == nil {
+		t.Fatal("expected error for an unknown options version")
+	}
+	opts, err := DecodeGenerationOptions(`{"temperature":0.5}`)
+	if err != nil || opts.Temperature == nil || *opts.Temperature != 0.5 {
+		t.Fatalf("unversioned blobs must still decode, got %+v, %v", opts, err)
+	}
+}
+
+func TestSamplingValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []GenerationOption
+		option string
+	}{
+		{"topK zero", []GenerationOption{WithSampling(TopK(0, 1))}, "sampling.topK"},
+		{"nucleus above one", []GenerationOption{WithSampling(Nucleus(1.5, 1))}, "sampling.probabilityThreshold"},
+		{"unknown mode", []GenerationOption{WithSampling(Sampling{})}, "sampling.mode"},
+		{"with legacy topK", []GenerationOption{WithTopK(5), WithSampling(Greedy())}, "topK"},
+		{"with legacy topP", []GenerationOption{WithTopP(0.5), WithSampling(Greedy())}, "topP"},
+		{"with legacy seed", []GenerationOption{WithSeed(1), WithSampling(TopK(5, 2))}, "seed"},
+		{"legacy topK and topP", []GenerationOption{WithTopK(5), WithTopP(0.5)}, "topP"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := resolveGenerationOptions(tc.opts).Validate()
+			var invalid *InvalidOptionError
+			if !errors.As(err, &invalid) || invalid.Option != tc.option {
+				t.Fatalf("expected invalid %s, got %v", tc.option, err)
+			}
+		})
+	}
+}
+
+func TestGenerationOptionsFlatten(t *testing.T) {
+	flat := resolveGenerationOptions([]GenerationOption{WithSampling(Nucleus(0.8, 3))}).Flatten()
+	if flat.Sampling != nil || flat.TopP == nil || *flat.TopP != 0.8 || flat.Seed == nil || *flat.Seed != 3 {
+		t.Fatalf("unexpected nucleus flattening %+v", flat)
+	}
+	flat = resolveGenerationOptions([]GenerationOption{WithTemperature(0.9), WithSampling(Greedy())}).Flatten()
+	if flat.Temperature == nil || *flat.Temperature != 0 {
+		t.Fatalf("greedy must flatten to temperature 0, got %+v", flat)
+	}
+	legacy := resolveGenerationOptions([]GenerationOption{WithTopK(4)})
+	if flat := legacy.Flatten(); flat.TopK != legacy.TopK {
+		t.Fatal("options without Sampling must be unchanged")
+	}
+}
@@ -11,6 +11,7 @@ type Schema struct {
 }
 
 // SchemaFromRawJSON constructs a Schema from a JSON blob that describes a DynamicGenerationSchema.
+// The blob is checked with Validate, so schemas the shim cannot build are rejected here.
 func SchemaFromRawJSON(data []byte) (Schema, error) {
 	if len(data) == 0 {
 		return Schema{}, errors.New("fundament: schema JSON must not be empty")
@@ -19,7 +20,11 @@ func SchemaFromRawJSON(data []byte) (Schema, error) {
 	if err := json.Unmarshal(data, &tmp); err != nil {
 		return Schema{}, err
 	}
-	return Schema{raw: json.RawMessage(append([]byte(nil), data...))}, nil
+	schema := Schema{raw: json.RawMessage(append([]byte(nil), data...))}
+	if err := schema.Validate(); err != nil {
+		return Schema{}, err
+	}
+	return schema, nil
 }
 
 // SchemaFromValue marshals a Go value to JSON and wraps it as a Schema.
@@ -0,0 +1,379 @@
+// Package schema builds fundament.Schema values with a typed, fluent API instead of nested
+// map literals:
+//
+//	plan, err := schema.Object("TravelPlan").
+//		Describe("A short trip plan.").
+//		Prop("destination", schema.String().Describe("City and country for the trip.")).
+//		Prop("highlights", schema.Array(schema.String()).Min(2).Max(4)).
+//		Prop("season", schema.Enum("spring", "summer", "autumn", "winter")).
+//		Prop("days", schema.Int().Min(1).Max(14)).
+//		Prop("budget", schema.Number().Min(0)).
+//		Build()
+//
+// Only arrays take element bounds, only integers and numbers take value bounds, and only
+// strings take choices or patterns, so most mistakes do not compile. The rest, such as
+// duplicate property names or Min above Max, are reported by Build as a
+// *fundament.SchemaError.
+//
+// An object can be reused and can contain itself, as long as the recursion passes through an
+// array that may be empty, that is one without a Min above zero:
+//
+//	address := schema.Object("Address").Prop("city", schema.String())
+//	comment := schema.Object("Comment").Prop("text", schema.String())
+//	comment.Prop("replies", schema.Array(comment))
+//	thread := schema.Object("Thread").
+//		Prop("home", address).
+//		Prop("work", address).
+//		Prop("comments", schema.Array(comment))
+//
+// Build declares such objects once under definitions and refers to them by name.
+package schema
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/domano/fundament"
+)
+
+// Node is a schema under construction. It is implemented by the types in this package.
+type Node interface {
+	wire(b *builder) node
+}
+
+// node is the JSON shape of a DynamicGenerationSchema decoded by the shim.
+type node struct {
+	Name            string     `json:"name,omitempty"`
+	Description     string     `json:"description,omitempty"`
+	Type            string     `json:"type,omitempty"`
+	Properties      []property `json:"properties,omitempty"`
+	Items           *node      `json:"items,omitempty"`
+	MinimumElements *int       `json:"minimumElements,omitempty"`
+	MaximumElements *int       `json:"maximumElements,omitempty"`
+	// AnyOf is a pointer so that an Enum without choices still reaches validation.
+	AnyOf   *[]string `json:"anyOf,omitempty"`
+	Minimum *float64  `json:"minimum,omitempty"`
+	Maximum *float64  `json:"maximum,omitempty"`
+	Pattern string    `json:"pattern,omitempty"`
+	Const   *string   `json:"const,omitempty"`
+	Ref     string    `json:"$ref,omitempty"`
+
+	Definitions map[string]node `json:"definitions,omitempty"`
+}
+
+type property struct {
+	Name   string `json:"name"`
+	Schema node   `json:"schema"`
+}
+
+// Build renders n as a fundament.Schema and validates it. Objects used in more than one place
+// or inside themselves are declared once under definitions and referenced by name, so each
+// such object needs a distinct name.
+func Build(n Node) (fundament.Schema, error) {
+	if n == nil {
+		return fundament.Schema{}, errors.New("schema: nil node")
+	}
+	b := &builder{
+		counting: true,
+		uses:     map[*ObjectType]int{},
+		path:     map[*ObjectType]bool{},
+		shared:   map[*ObjectType]bool{},
+	}
+	n.wire(b)
+	b.counting = false
+	root := n.wire(b)
+	if b.err != nil {
+		return fundament.Schema{}, b.err
+	}
+	root.Definitions = b.defs
+	return fundament.SchemaFromValue(root)
+}
+
+// builder carries the state of Build. A first, counting pass finds the objects that are
+// shared or recursive; the second pass renders them as definitions.
+type builder struct {
+	counting bool
+	uses     map[*ObjectType]int
+	path     map[*ObjectType]bool
+	shared   map[*ObjectType]bool
+
+	defs    map[string]node
+	defined map[string]*ObjectType
+	err     error
+}
+
+// reference registers o as a definition on first use and returns a reference to it.
+func (b *builder) reference(o *ObjectType) node {
+	if b.defined[o.name] == o {
+		return node{Ref: "#/definitions/" + o.name}
+	}
+	switch {
+	case o.name == "":
+		b.fail(errors.New("schema: an object used in several places or inside itself needs a name"))
+	case b.defined[o.name] != nil:
+		b.fail(fmt.Errorf("schema: two different objects are named %q", o.name))
+	}
+	if b.defs == nil {
+		b.defs, b.defined = map[string]node{}, map[string]*ObjectType{}
+	}
+	b.defined[o.name] = o
+	b.defs[o.name] = o.inline(b)
+	return node{Ref: "#/definitions/" + o.name}
+}
+
+func (b *builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// MustBuild is like Build but panics on error. It suits schemas declared as package variables.
+func MustBuild(n Node) fundament.Schema {
+	s, err := Build(n)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ObjectType is an object with named properties, kept in the order they were added.
+type ObjectType struct {
+	name        string
+	description string
+	props       []objectProp
+}
+
+type objectProp struct {
+	name   string
+	schema Node
+}
+
+// Object starts an object schema. The name identifies the generated type to the model.
+func Object(name string) *ObjectType {
+	return &ObjectType{name: name}
+}
+
+// Describe documents the object for the model.
+func (o *ObjectType) Describe(text string) *ObjectType {
+	o.description = text
+	return o
+}
+
+// Prop appends a property. It panics if s is nil.
+func (o *ObjectType) Prop(name string, s Node) *ObjectType {
+	if s == nil {
+		panic(fmt.Sprintf("schema: property %q has a nil schema", name))
+	}
+	o.props = append(o.props, objectProp{name: name, schema: s})
+	return o
+}
+
+// Build renders the object as a fundament.Schema and validates it.
+func (o *ObjectType) Build() (fundament.Schema, error) {
+	return Build(o)
+}
+
+func (o *ObjectType) wire(b *builder) node {
+	if b.counting {
+		b.uses[o]++
+		if b.path[o] || b.uses[o] > 1 {
+			b.shared[o] = true
+			return node{}
+		}
+		b.path[o] = true
+		defer delete(b.path, o)
+		o.inline(b)
+		return node{}
+	}
+	if b.shared[o] {
+		return b.reference(o)
+	}
+	return o.inline(b)
+}
+
+func (o *ObjectType) inline(b *builder) node {
+	n := node{Name: o.name, Description: o.description, Type: "object"}
+	for _, p := range o.props {
+		n.Properties = append(n.Properties, property{Name: p.name, Schema: p.schema.wire(b)})
+	}
+	return n
+}
+
+// ArrayType is a list of elements that share one schema.
+type ArrayType struct {
+	items       Node
+	description string
+	min, max    *int
+}
+
+// Array starts an array whose elements follow items. It panics if items is nil.
+func Array(items Node) *ArrayType {
+	if items == nil {
+		panic("schema: array has a nil element schema")
+	}
+	return &ArrayType{items: items}
+}
+
+// Describe documents the array for the model.
+func (a *ArrayType) Describe(text string) *ArrayType {
+	a.description = text
+	return a
+}
+
+// Min sets the minimum number of elements.
+func (a *ArrayType) Min(n int) *ArrayType {
+	a.min = &n
+	return a
+}
+
+// Max sets the maximum number of elements.
+func (a *ArrayType) Max(n int) *ArrayType {
+	a.max = &n
+	return a
+}
+
+// Build renders the array as a fundament.Schema and validates it.
+func (a *ArrayType) Build() (fundament.Schema, error) {
+	return Build(a)
+}
+
+func (a *ArrayType) wire(b *builder) node {
+	items := a.items.wire(b)
+	return node{
+		Description:     a.description,
+		Type:            "array",
+		Items:           &items,
+		MinimumElements: a.min,
+		MaximumElements: a.max,
+	}
+}
+
+// StringType is free text, one of a fixed set of choices when built with Enum, or a fixed
+// value when built with Const.
+type StringType struct {
+	description string
+	choices     *[]string
+	pattern     string
+	constant    *string
+}
+
+// String starts a string schema.
+func String() *StringType {
+	return &StringType{}
+}
+
+// Enum starts a string schema restricted to choices.
+func Enum(choices ...string) *StringType {
+	list := append([]string{}, choices...)
+	return &StringType{choices: &list}
+}
+
+// Const starts a string schema that always generates value.
+func Const(value string) *StringType {
+	return &StringType{constant: &value}
+}
+
+// Describe documents the string for the model.
+func (s *StringType) Describe(text string) *StringType {
+	s.description = text
+	return s
+}
+
+// Pattern requires the whole string to match the regular expression re. Build rejects
+// patterns that do not compile as Go regular expressions, and patterns on an Enum or Const.
+func (s *StringType) Pattern(re string) *StringType {
+	s.pattern = re
+	return s
+}
+
+func (s *StringType) wire(*builder) node {
+	return node{Description: s.description, Type: "string", AnyOf: s.choices, Pattern: s.pattern, Const: s.constant}
+}
+
+// IntType is a whole number.
+type IntType struct {
+	description string
+	min, max    *float64
+}
+
+// Int starts an integer schema.
+func Int() *IntType {
+	return &IntType{}
+}
+
+// Describe documents the integer for the model.
+func (i *IntType) Describe(text string) *IntType {
+	i.description = text
+	return i
+}
+
+// Min sets the smallest allowed value.
+func (i *IntType) Min(n int) *IntType {
+	v := float64(n)
+	i.min = &v
+	return i
+}
+
+// Max sets the largest allowed value.
+func (i *IntType) Max(n int) *IntType {
+	v := float64(n)
+	i.max = &v
+	return i
+}
+
+func (i *IntType) wire(*builder) node {
+	return node{Description: i.description, Type: "integer", Minimum: i.min, Maximum: i.max}
+}
+
+// NumberType is a floating-point number, generated as a Double on device.
+type NumberType struct {
+	description string
+	min, max    *float64
+}
+
+// Number starts a floating-point schema.
+func Number() *NumberType {
+	return &NumberType{}
+}
+
+// Describe documents the number for the model.
+func (n *NumberType) Describe(text string) *NumberType {
+	n.description = text
+	return n
+}
+
+// Min sets the smallest allowed value.
+func (n *NumberType) Min(v float64) *NumberType {
+	n.min = &v
+	return n
+}
+
+// Max sets the largest allowed value.
+func (n *NumberType) Max(v float64) *NumberType {
+	n.max = &v
+	return n
+}
+
+func (n *NumberType) wire(*builder) node {
+	return node{Description: n.description, Type: "number", Minimum: n.min, Maximum: n.max}
+}
+
+// BoolType is true or false.
+type BoolType struct {
+	description string
+}
+
+// Bool starts a boolean schema.
+func Bool() *BoolType {
+	return &BoolType{}
+}
+
+// Describe documents the boolean for the model.
+func (b *BoolType) Describe(text string) *BoolType {
+	b.description = text
+	return b
+}
+
+func (b *BoolType) wire(*builder) node {
+	return node{Description: b.description, Type: "boolean"}
+}
@@ -0,0 +1,150 @@
+package schema
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/domano/fundament"
+)
+
+func TestBuildTravelPlan(t *testing.T) {
+	plan, err := Object("TravelPlan").
+		Describe("A short trip plan.").
+		Prop("destination", String().Describe("City and country for the trip.")).
+		Prop("highlights", Array(String()).Min(2).Max(4)).
+		Prop("season", Enum("spring", "autumn")).
+		Prop("days", Int()).
+		Prop("budget", Bool()).
+		Build()
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	want := `{"name":"TravelPlan","description":"A short trip plan.","type":"object","properties":[` +
+		`{"name":"destination","schema":{"description":"City and country for the trip.","type":"string"}},` +
+		`{"name":"highlights","schema":{"type":"array","items":{"type":"string"},"minimumElements":2,"maximumElements":4}},` +
+		`{"name":"season","schema":{"type":"string","anyOf":["spring","autumn"]}},` +
+		`{"name":"days","schema":{"type":"integer"}},` +
+		`{"name":"budget","schema":{"type":"boolean"}}]}`
+	if plan.String() != want {
+		t.Fatalf("schema = %s\nwant     %s", plan, want)
+	}
+	if plan.Name() != "TravelPlan" {
+		t.Fatalf("Name = %q", plan.Name())
+	}
+}
+
+func TestBuildGuides(t *testing.T) {
+	review := MustBuild(Object("Review").
+		Prop("rating", Int().Min(1).Max(5)).
+		Prop("price", Number().Min(0.5)).
+		Prop("sku", String().Pattern(`[A-Z]{3}-\d+`)).
+		Prop("kind", Const("review")))
+	want := `{"name":"Review","type":"object","properties":[` +
+		`{"name":"rating","schema":{"type":"integer","minimum":1,"maximum":5}},` +
+		`{"name":"price","schema":{"type":"number","minimum":0.5}},` +
+		`{"name":"sku","schema":{"type":"string","pattern":"[A-Z]{3}-\\d+"}},` +
+		`{"name":"kind","schema":{"type":"string","const":"review"}}]}`
+	if review.String() != want {
+		t.Fatalf("schema = %s\nwant     %s", review, want)
+	}
+	if err := review.CheckResponse([]byte(`{"rating":9,"price":1,"sku":"ABC-1","kind":"review"}`)); err == nil {
+		t.Fatal("expected the rating to violate its maximum")
+	}
+}
+
+func TestBuildReportsValidationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    Node
+		pointer string
+	}{
+		{"min above max", Object("X").Prop("tags", Array(String()).Min(3).Max(1)), "/properties/0/schema"},
+		{"duplicate property", Object("X").Prop("a", Int()).Prop("a", Bool()), "/properties/1"},
+		{"empty property name", Object("X").Prop("", Int()), "/properties/0"},
+		{"enum without choices", Array(Enum()), "/items"},
+		{"object without properties", Array(Object("Empty")), "/items"},
+		{"range", Number().Min(2).Max(1), ""},
+		{"bad pattern", String().Pattern("(unclosed"), ""},
+		{"enum with pattern", Object("X").Prop("season", Enum("spring").Pattern("s.*")), "/properties/0/schema"},
+		{"const with pattern", Const("a").Pattern("a"), ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Build(tc.node)
+			var schemaErr *fundament.SchemaError
+			if !errors.As(err, &schemaErr) || schemaErr.Issues[0].Pointer != tc.pointer {
+				t.Fatalf("expected an issue at %s, got %v", tc.pointer, err)
+			}
+		})
+	}
+}
+
+func TestMustBuildPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	MustBuild(Array(Int()).Min(-1))
+}
+
+func TestBuildSharedAndRecursiveObjects(t *testing.T) {
+	address := Object("Address").Prop("city", String())
+	comment := Object("Comment").Prop("text", String())
+	comment.Prop("replies", Array(comment))
+	thread, err := Object("Thread").
+		Prop("home", address).
+		Prop("work", address).
+		Prop("comments", Array(comment).Max(5)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	want := `{"name":"Thread","type":"object","properties":[` +
+		`{"name":"home","schema":{"$ref":"#/definitions/Address"}},` +
+		`{"name":"work","schema":{"$ref":"#/definitions/Address"}},` +
+		`{"name":"comments","schema":{"type":"array","items":{"$ref":"#/definitions/Comment"},"maximumElements":5}}],` +
+		`"definitions":{` +
+		`"Address":{"name":"Address","type":"object","properties":[{"name":"city","schema":{"type":"string"}}]},` +
+		`"Comment":{"name":"Comment","type":"object","properties":[` +
+		`{"name":"text","schema":{"type":"string"}},` +
+		`{"name":"replies","schema":{"type":"array","items":{"$ref":"#/definitions/Comment"}}}]}}}`
+	if thread.String() != want {
+		t.Fatalf("schema = %s\nwant     %s", thread, want)
+	}
+
+	root := MustBuild(comment)
+	if root.Name() != "Comment" {
+		t.Fatalf("a recursive root must refer to its definition, got %s", root)
+	}
+	if err := root.CheckResponse([]byte(`{"text":"a","replies":[{"text":"b","replies":[{"text":7,"replies":[]}]}]}`)); err == nil {
+		t.Fatal("expected a violation in a nested reply")
+	}
+}
+
+func TestBuildRejectsAmbiguousDefinitions(t *testing.T) {
+	shared := Object("").Prop("x", Int())
+	if _, err := Object("Root").Prop("a", shared).Prop("b", shared).Build(); err == nil {
+		t.Fatal("expected an error for a shared object without a name")
+	}
+
+	first := Object("Item").Prop("x", Int())
+	second := Object("Item").Prop("y", Int())
+	if _, err := Object("Root").Prop("a", first).Prop("b", first).Prop("c", second).Prop("d", second).Build(); err == nil {
+		t.Fatal("expected an error for two shared objects with one name")
+	}
+
+	node := Object("Node").Prop("value", Int())
+	node.Prop("next", node)
+	_, err := Build(node)
+	var schemaErr *fundament.SchemaError
+	if !errors.As(err, &schemaErr) || schemaErr.Issues[0].Pointer != "/definitions/Node" {
+		t.Fatalf("expected unbounded recursion to be rejected, got %v", err)
+	}
+
+	tree := Object("Tree").Prop("value", Int())
+	tree.Prop("children", Array(tree).Min(1))
+	if _, err := Build(tree); !errors.As(err, &schemaErr) || schemaErr.Issues[0].Pointer != "/definitions/Tree" {
+		t.Fatalf("expected recursion through a non-empty array to be rejected, got %v", err)
+	}
+}
@@ -11,14 +11,14 @@ func TestSchemaFromRawJSON(t *testing.T) {
 		t.Fatal("expected error for empty payload")
 	}
 
-	blob := []byte(`{"type":"object","name":"Example"}`)
+	blob := []byte(`{"type":"string","name":"Example"}`)
 	s, err := SchemaFromRawJSON(blob)
 	if err != nil {
 		t.Fatalf("SchemaFromRawJSON error: %v", err)
 	}
 
 	blob[2] = 'X'
-	if s.String() != `{"type":"object","name":"Example"}` {
+	if s.String() != `{"type":"string","name":"Example"}` {
 		t.Fatalf("schema should retain original bytes, got %s", s.String())
 	}
 
@@ -28,16 +28,16 @@ func TestSchemaFromRawJSON(t *testing.T) {
 	if err != nil {
 		t.Fatalf("marshal error: %v", err)
 	}
-	if string(out) != `{"schema":{"type":"object","name":"Example"}}` {
+	if string(out) != `{"schema":{"type":"string","name":"Example"}}` {
 		t.Fatalf("unexpected marshal result %s", string(out))
 	}
 
 	raw := s.Raw()
-	if string(raw) != `{"type":"object","name":"Example"}` {
+	if string(raw) != `{"type":"string","name":"Example"}` {
 		t.Fatalf("unexpected raw %s", string(raw))
 	}
 	raw[2] = 'Z'
-	if s.String() != `{"type":"object","name":"Example"}` {
+	if s.String() != `{"type":"string","name":"Example"}` {
 		t.Fatal("schema raw should be immutable copy")
 	}
 }
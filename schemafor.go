@@ -0,0 +1,351 @@
+package fundament
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// schemaCache holds the SchemaFor result for each type, error included.
+var schemaCache sync.Map // reflect.Type -> schemaForResult
+
+type schemaForResult struct {
+	schema Schema
+	err    error
+}
+
+// SchemaFor derives a Schema from the Go type T, so the schema and the type that decodes the
+// response cannot drift apart. Structs become objects named after the type whose properties
+// follow field order and use the json tag names; strings, integers, and booleans map to the
+// matching primitives, with floats as numbers and unsigned integers bounded below by zero;
+// slices and arrays become arrays; a pointer T and pointer elements are followed. Fields are
+// annotated with a fundament tag:
+//
+//	type TravelPlan struct {
+//		Destination string   `json:"destination" fundament:"description=City and country"`
+//		Highlights  []string `json:"highlights" fundament:"min=2,max=4"`
+//		Season      string   `json:"season" fundament:"enum=spring|summer|autumn|winter"`
+//	}
+//
+// description documents the field for the model. min and max bound the element count of a
+// slice or the value of an integer or float. enum restricts a string to the listed choices,
+// pattern to a regular expression, and const to a single value. Recursive types, such as a
+// comment with a slice of replies, are described once under definitions and referenced; the
+// recursion must pass through a slice without a min above zero so that values can end.
+//
+// The model generates every property, so a schema cannot mark a field optional. Fields that
+// suggest otherwise, pointer fields and fields tagged omitempty, are rejected rather than
+// silently made required. Byte slices, which encoding/json writes as base64 text, are
+// rejected too, as are maps, interfaces, channels, and functions. Results are cached per
+// type.
+func SchemaFor[T any]() (Schema, error) {
+	return schemaForType(reflect.TypeFor[T]())
+}
+
+func schemaForType(t reflect.Type) (Schema, error) {
+	if cached, ok := schemaCache.Load(t); ok {
+		res := cached.(schemaForResult)
+		return res.schema, res.err
+	}
+	var res schemaForResult
+	r := &schemaReflector{visiting: map[reflect.Type]bool{}, recursive: map[reflect.Type]bool{}}
+	node, err := r.node(t, fieldTag{}, "")
+	if err == nil && len(r.defs) > 0 {
+		node.Definitions = r.defs
+	}
+	if err != nil {
+		res.err = fmt.Errorf("fundament: SchemaFor[%s]: %w", t, err)
+	} else {
+		res.schema, res.err = SchemaFromValue(node)
+	}
+	schemaCache.Store(t, res)
+	return res.schema, res.err
+}
+
+// RespondAs requests a structured response shaped by SchemaFor[T] and decodes it into a T.
+func RespondAs[T any](ctx context.Context, s *Session, prompt string, opts ...GenerationOption) (T, error) {
+	var out T
+	schema, err := SchemaFor[T]()
+	if err != nil {
+		return out, err
+	}
+	res, err := s.RespondStructured(ctx, prompt, schema, opts...)
+	if err != nil {
+		return out, err
+	}
+	if err := json.Unmarshal(res.JSON, &out); err != nil {
+		return out, fmt.Errorf("fundament: decode %T: %w", out, err)
+	}
+	return out, nil
+}
+
+// fieldTag is the parsed fundament struct tag.
+type fieldTag struct {
+	description string
+	min, max    *float64
+	enum        []string
+	pattern     string
+	constant    *string
+}
+
+var fieldTagKeys = []string{"description", "min", "max", "enum", "pattern", "const"}
+
+// parseFieldTag reads key=value pairs separated by commas. A comma that is not followed by a
+// known key belongs to the previous value, so descriptions may contain commas.
+func parseFieldTag(tag string) (fieldTag, error) {
+	var out fieldTag
+	if tag == "" {
+		return out, nil
+	}
+	var pairs []string
+	for _, part := range strings.Split(tag, ",") {
+		if len(pairs) > 0 && !startsWithTagKey(part) {
+			pairs[len(pairs)-1] += "," + part
+			continue
+		}
+		pairs = append(pairs, part)
+	}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return out, fmt.Errorf("malformed fundament tag %q", pair)
+		}
+		switch key = strings.TrimSpace(key); key {
+		case "description":
+			out.description = value
+		case "min", "max":
+			n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return out, fmt.Errorf("fundament tag %s=%q must be a number", key, value)
+			}
+			if key == "min" {
+				out.min = &n
+			} else {
+				out.max = &n
+			}
+		case "enum":
+			out.enum = strings.Split(value, "|")
+		case "pattern":
+			out.pattern = value
+		case "const":
+			out.constant = &value
+		default:
+			return out, fmt.Errorf("unknown fundament tag key %q", key)
+		}
+	}
+	return out, nil
+}
+
+func startsWithTagKey(part string) bool {
+	key, _, ok := strings.Cut(part, "=")
+	if !ok {
+		return false
+	}
+	key = strings.TrimSpace(key)
+	for _, known := range fieldTagKeys {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaReflector walks a type, tracking the structs on the current path. Structs met again
+// on their own path are recursive and move to defs, keyed by type name.
+type schemaReflector struct {
+	visiting  map[reflect.Type]bool
+	recursive map[reflect.Type]bool
+	defs      map[string]schemaNode
+	defTypes  map[string]reflect.Type
+}
+
+// node builds the schema for t. path names the field being described in errors and is empty
+// for the root type.
+func (r *schemaReflector) node(t reflect.Type, tag fieldTag, path string) (schemaNode, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if (tag.min != nil || tag.max != nil) && !boundedKind(t.Kind()) {
+		return schemaNode{}, pathError(path, "min and max apply to slices, arrays, and numbers, not %s", t.Kind())
+	}
+	if (tag.enum != nil || tag.pattern != "" || tag.constant != nil) && t.Kind() != reflect.String {
+		return schemaNode{}, pathError(path, "enum, pattern, and const apply to strings, not %s", t.Kind())
+	}
+	node := schemaNode{Description: tag.description}
+	switch t.Kind() {
+	case reflect.String:
+		node.Type = "string"
+		node.AnyOf = tag.enum
+		node.Pattern = tag.pattern
+		node.Const = tag.constant
+	case reflect.Bool:
+		node.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		node.Type = "integer"
+		node.Minimum, node.Maximum = tag.min, tag.max
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// Negative output would fail to decode, so unsigned integers start at zero.
+		node.Type = "integer"
+		node.Minimum, node.Maximum = tag.min, tag.max
+		if tag.min == nil {
+			zero := 0.0
+			node.Minimum = &zero
+		} else if *tag.min < 0 {
+			return schemaNode{}, pathError(path, "min %v is negative for unsigned %s", *tag.min, t.Kind())
+		}
+	case reflect.Float32, reflect.Float64:
+		node.Type = "number"
+		node.Minimum, node.Maximum = tag.min, tag.max
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return schemaNode{}, pathError(path, "%s encodes as base64 text, not an array; use a string", t)
+		}
+		items, err := r.node(t.Elem(), fieldTag{}, path+"[]")
+		if err != nil {
+			return schemaNode{}, err
+		}
+		node.Type = "array"
+		node.Items = &items
+		if node.MinimumElements, err = elementBound(tag.min); err != nil {
+			return schemaNode{}, pathError(path, "%w", err)
+		}
+		if node.MaximumElements, err = elementBound(tag.max); err != nil {
+			return schemaNode{}, pathError(path, "%w", err)
+		}
+		if t.Kind() == reflect.Array && tag.min == nil && tag.max == nil {
+			n := t.Len()
+			node.MinimumElements, node.MaximumElements = &n, &n
+		}
+	case reflect.Struct:
+		if r.visiting[t] || r.defTypes[t.Name()] == t {
+			r.recursive[t] = true
+			return schemaNode{Description: tag.description, Ref: definitionsPrefix + t.Name()}, nil
+		}
+		r.visiting[t] = true
+		defer delete(r.visiting, t)
+		props, err := r.properties(t, path)
+		if err != nil {
+			return schemaNode{}, err
+		}
+		if len(props) == 0 {
+			return schemaNode{}, pathError(path, "struct %s has no exported fields", t)
+		}
+		if r.recursive[t] {
+			if other, ok := r.defTypes[t.Name()]; ok && other != t {
+				return schemaNode{}, pathError(path, "recursive types %s and %s share the name %s", other, t, t.Name())
+			}
+			if r.defs == nil {
+				r.defs, r.defTypes = map[string]schemaNode{}, map[string]reflect.Type{}
+			}
+			r.defs[t.Name()] = schemaNode{Name: t.Name(), Properties: props}
+			r.defTypes[t.Name()] = t
+			return schemaNode{Description: tag.description, Ref: definitionsPrefix + t.Name()}, nil
+		}
+		node.Name = t.Name()
+		node.Properties = props
+	default:
+		return schemaNode{}, pathError(path, "unsupported kind %s", t.Kind())
+	}
+	return node, nil
+}
+
+// properties lists the fields of t the way encoding/json sees them: exported fields under
+// their json names, json:"-" skipped, and untagged embedded structs flattened.
+func (r *schemaReflector) properties(t reflect.Type, path string) ([]schemaProperty, error) {
+	var props []schemaProperty
+	for i := range t.NumField() {
+		field := t.Field(i)
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(jsonTag, ",")
+		fieldPath := field.Name
+		if path != "" {
+			fieldPath = path + "." + field.Name
+		}
+		embedded := field.Anonymous && name == "" && indirect(field.Type).Kind() == reflect.Struct
+		if !embedded && !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Pointer {
+			return nil, pathError(fieldPath, "pointer fields are optional in Go, but every generated property is required")
+		}
+		if hasJSONOption(options, "omitempty") || hasJSONOption(options, "omitzero") {
+			return nil, pathError(fieldPath, "omitempty and omitzero fields are optional in Go, but every generated property is required")
+		}
+		if embedded {
+			inner, err := r.properties(field.Type, path)
+			if err != nil {
+				return nil, err
+			}
+			props = append(props, inner...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		tag, err := parseFieldTag(field.Tag.Get("fundament"))
+		if err != nil {
+			return nil, pathError(fieldPath, "%w", err)
+		}
+		child, err := r.node(field.Type, tag, fieldPath)
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, schemaProperty{Name: name, Schema: child})
+	}
+	return props, nil
+}
+
+// indirect returns the element type of a pointer and t otherwise.
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+// hasJSONOption reports whether the comma-separated json tag options contain option.
+func hasJSONOption(options, option string) bool {
+	for _, o := range strings.Split(options, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// elementBound converts a min or max tag into an element count.
+func elementBound(v *float64) (*int, error) {
+	if v == nil {
+		return nil, nil
+	}
+	n := int(*v)
+	if float64(n) != *v || n < 0 {
+		return nil, fmt.Errorf("element bounds must be non-negative integers, got %v", *v)
+	}
+	return &n, nil
+}
+
+func boundedKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Slice, reflect.Array, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func pathError(path, format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if path == "" {
+		return err
+	}
+	return fmt.Errorf("field %s: %w", path, err)
+}
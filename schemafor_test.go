@@ -0,0 +1,194 @@
+package fundament
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type tripStop struct {
+	City   string `json:"city" fundament:"description=City name, without the country"`
+	Nights int    `json:"nights"`
+}
+
+type tripPlan struct {
+	Destination string     `json:"destination" fundament:"description=City and country"`
+	Highlights  []string   `json:"highlights" fundament:"min=2,max=4"`
+	Season      string     `json:"season" fundament:"enum=spring|summer|autumn|winter"`
+	Stops       []tripStop `json:"stops"`
+	Budget      bool       `json:"budget"`
+	Internal    string     `json:"-"`
+	notes       string
+}
+
+func TestSchemaForStruct(t *testing.T) {
+	schema, err := SchemaFor[tripPlan]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	want := `{"name":"tripPlan","properties":[` +
+		`{"name":"destination","schema":{"description":"City and country","type":"string"}},` +
+		`{"name":"highlights","schema":{"type":"array","items":{"type":"string"},"minimumElements":2,"maximumElements":4}},` +
+		`{"name":"season","schema":{"type":"string","anyOf":["spring","summer","autumn","winter"]}},` +
+		`{"name":"stops","schema":{"type":"array","items":{"name":"tripStop","properties":[` +
+		`{"name":"city","schema":{"description":"City name, without the country","type":"string"}},` +
+		`{"name":"nights","schema":{"type":"integer"}}]}}},` +
+		`{"name":"budget","schema":{"type":"boolean"}}]}`
+	if schema.String() != want {
+		t.Fatalf("schema = %s\nwant     %s", schema, want)
+	}
+	again, _ := SchemaFor[*tripPlan]()
+	if again.String() != want {
+		t.Fatalf("pointer types must describe their element, got %s", again)
+	}
+}
+
+func TestSchemaForGuides(t *testing.T) {
+	type review struct {
+		Rating int     `json:"rating" fundament:"min=1,max=5"`
+		Price  float64 `json:"price" fundament:"min=0.5"`
+		SKU    string  `json:"sku" fundament:"pattern=[A-Z]{3}-\\d{1,4}"`
+		Kind   string  `json:"kind" fundament:"const=review"`
+		Votes  uint    `json:"votes"`
+		Stars  uint8   `json:"stars" fundament:"min=1,max=5"`
+		Bytes  [2]byte `json:"bytes"`
+	}
+	schema, err := SchemaFor[review]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	want := `{"name":"review","properties":[` +
+		`{"name":"rating","schema":{"type":"integer","minimum":1,"maximum":5}},` +
+		`{"name":"price","schema":{"type":"number","minimum":0.5}},` +
+		`{"name":"sku","schema":{"type":"string","pattern":"[A-Z]{3}-\\d{1,4}"}},` +
+		`{"name":"kind","schema":{"type":"string","const":"review"}},` +
+		`{"name":"votes","schema":{"type":"integer","minimum":0}},` +
+		`{"name":"stars","schema":{"type":"integer","minimum":1,"maximum":5}},` +
+		`{"name":"bytes","schema":{"type":"array","items":{"type":"integer","minimum":0},"minimumElements":2,"maximumElements":2}}]}`
+	if schema.String() != want {
+		t.Fatalf("schema = %s\nwant     %s", schema, want)
+	}
+}
+
+type thread struct {
+	Title string    `json:"title"`
+	Root  comment   `json:"root"`
+	Top   []comment `json:"top" fundament:"max=3"`
+}
+
+type comment struct {
+	Text    string    `json:"text"`
+	Replies []comment `json:"replies"`
+}
+
+func TestSchemaForRecursiveType(t *testing.T) {
+	schema, err := SchemaFor[thread]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	want := `{"name":"thread","properties":[` +
+		`{"name":"title","schema":{"type":"string"}},` +
+		`{"name":"root","schema":{"$ref":"#/definitions/comment"}},` +
+		`{"name":"top","schema":{"type":"array","items":{"$ref":"#/definitions/comment"},"maximumElements":3}}],` +
+		`"definitions":{"comment":{"name":"comment","properties":[` +
+		`{"name":"text","schema":{"type":"string"}},` +
+		`{"name":"replies","schema":{"type":"array","items":{"$ref":"#/definitions/comment"}}}]}}}`
+	if schema.String() != want {
+		t.Fatalf("schema = %s\nwant     %s", schema, want)
+	}
+
+	root, err := SchemaFor[comment]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	if root.Name() != "comment" {
+		t.Fatalf("a recursive root must be named after its definition, got %s", root)
+	}
+}
+
+func TestSchemaForRejectsUnsupportedKinds(t *testing.T) {
+	type withMap struct {
+		Tags map[string]string
+	}
+	type withInterface struct {
+		Inner struct{ Value any }
+	}
+	type withChan struct {
+		Items []chan int
+	}
+	type badTag struct {
+		Count int `fundament:"enum=a|b"`
+	}
+	type badBound struct {
+		Tags []string `fundament:"min=1.5"`
+	}
+	type withBytes struct {
+		Data []byte `json:"data"`
+	}
+	type negativeUnsigned struct {
+		Count uint `fundament:"min=-1"`
+	}
+	type chain struct {
+		Next *chain
+	}
+	type omitted struct {
+		Note string `json:"note,omitempty"`
+	}
+	type Extra struct {
+		Note string `json:"note"`
+	}
+	type embeddedPointer struct {
+		*Extra
+	}
+	tests := []struct {
+		name string
+		fn   func() (Schema, error)
+		want string
+	}{
+		{"map", SchemaFor[withMap], "field Tags: unsupported kind map"},
+		{"interface", SchemaFor[withInterface], "field Inner.Value: unsupported kind interface"},
+		{"chan", SchemaFor[withChan], "field Items[]: unsupported kind chan"},
+		{"complex", SchemaFor[complex128], "unsupported kind complex128"},
+		{"tag", SchemaFor[badTag], "field Count: enum, pattern, and const apply to strings"},
+		{"element bound", SchemaFor[badBound], "field Tags: element bounds must be non-negative integers"},
+		{"byte slice", SchemaFor[withBytes], "field Data: []uint8 encodes as base64 text"},
+		{"negative unsigned bound", SchemaFor[negativeUnsigned], "field Count: min -1 is negative for unsigned uint"},
+		{"pointer field", SchemaFor[chain], "field Next: pointer fields are optional in Go"},
+		{"omitempty", SchemaFor[omitted], "field Note: omitempty and omitzero fields are optional in Go"},
+		{"embedded pointer", SchemaFor[embeddedPointer], "field Extra: pointer fields are optional in Go"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tc.fn()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestRespondAs(t *testing.T) {
+	var gotSchema Schema
+	backend := &stubBackend{
+		respondStructured: func(_ string, schema Schema, _ GenerationOptions) (string, error) {
+			gotSchema = schema
+			return `{"destination":"Kyoto, Japan","highlights":["Arashiyama","Gion"],"season":"autumn","stops":[{"city":"Kyoto","nights":2}],"budget":false}`, nil
+		},
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	plan, err := RespondAs[tripPlan](context.Background(), session, "Plan a trip")
+	if err != nil {
+		t.Fatalf("RespondAs error: %v", err)
+	}
+	if plan.Destination != "Kyoto, Japan" || len(plan.Highlights) != 2 || plan.Stops[0].Nights != 2 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	if want, _ := SchemaFor[tripPlan](); gotSchema.String() != want.String() {
+		t.Fatalf("backend received schema %s", gotSchema)
+	}
+}
@@ -0,0 +1,73 @@
+package fundament
+
+import (
+	"slices"
+	"strings"
+)
+
+const definitionsPrefix = "#/definitions/"
+
+// maxSchemaDepth bounds how many references a response check follows along one path, so a
+// recursive schema cannot drive it arbitrarily deep.
+const maxSchemaDepth = 64
+
+// definitionName extracts the definition a $ref points at.
+func definitionName(ref string) (string, bool) {
+	name, ok := strings.CutPrefix(ref, definitionsPrefix)
+	return name, ok && name != ""
+}
+
+// sortedDefinitions returns the definition names in a stable order.
+func sortedDefinitions(defs map[string]schemaNode) []string {
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// requiredRefs collects the definitions node refers to without passing through an array that
+// may be empty. Every property is required and an array with minimumElements above zero
+// needs an element, so following only these edges back to a definition means its values
+// could never end.
+func requiredRefs(node schemaNode, out map[string]bool) {
+	if name, ok := definitionName(node.Ref); ok {
+		out[name] = true
+	}
+	for _, prop := range node.Properties {
+		requiredRefs(prop.Schema, out)
+	}
+	if node.Items != nil && node.MinimumElements != nil && *node.MinimumElements > 0 {
+		requiredRefs(*node.Items, out)
+	}
+}
+
+// unboundedDefinitions returns the definitions that contain themselves through required
+// properties and non-empty arrays alone.
+func unboundedDefinitions(defs map[string]schemaNode) []string {
+	edges := make(map[string]map[string]bool, len(defs))
+	for name, def := range defs {
+		edges[name] = map[string]bool{}
+		requiredRefs(def, edges[name])
+	}
+	var out []string
+	for _, start := range sortedDefinitions(defs) {
+		seen := map[string]bool{}
+		queue := []string{start}
+		for len(queue) > 0 && !seen[start] {
+			next := queue[0]
+			queue = queue[1:]
+			for target := range edges[next] {
+				if !seen[target] {
+					seen[target] = true
+					queue = append(queue, target)
+				}
+			}
+		}
+		if seen[start] {
+			out = append(out, start)
+		}
+	}
+	return out
+}
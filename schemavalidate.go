@@ -0,0 +1,211 @@
+package fundament
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SchemaIssue is one problem found by Schema.Validate.
+type SchemaIssue struct {
+	// Pointer is the JSON pointer (RFC 6901) of the offending node within the schema, empty for
+	// the root.
+	Pointer string
+	Message string
+}
+
+func (i SchemaIssue) String() string {
+	if i.Pointer == "" {
+		return "(root): " + i.Message
+	}
+	return i.Pointer + ": " + i.Message
+}
+
+// SchemaError reports every problem Schema.Validate found.
+type SchemaError struct {
+	Issues []SchemaIssue
+}
+
+func (e *SchemaError) Error() string {
+	parts := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		parts[i] = issue.String()
+	}
+	return "fundament: invalid schema: " + strings.Join(parts, "; ")
+}
+
+// Validate checks the schema against the subset of DynamicGenerationSchema that the on-device
+// shim can build, so mistakes surface before the schema reaches a Mac. Objects are nodes with
+// properties, and the supported types are string, integer, number (or double), boolean, and
+// array. Integers and numbers take minimum and maximum; strings take at most one of anyOf,
+// pattern, and const. Patterns must be valid Go regular expressions, since responses are
+// checked against them in Go. The root may declare named objects and enums under definitions
+// for nodes to reference with {"$ref": "#/definitions/<name>"}; a definition may contain
+// itself only through an array without a minimumElements above zero, which can be empty and
+// so end the recursion. Problems are reported together as a *SchemaError.
+func (s Schema) Validate() error {
+	node, err := s.root()
+	if err != nil {
+		return err
+	}
+	v := &schemaValidator{defs: node.Definitions}
+	v.node(node, "", true)
+	for _, name := range sortedDefinitions(node.Definitions) {
+		v.definition(name, node.Definitions[name], "/definitions/"+escapePointer(name))
+	}
+	for _, name := range unboundedDefinitions(node.Definitions) {
+		v.issues = append(v.issues, SchemaIssue{
+			Pointer: "/definitions/" + escapePointer(name),
+			Message: fmt.Sprintf("definition %q contains itself through required properties; refer to it from an array that may be empty instead", name),
+		})
+	}
+	if len(v.issues) > 0 {
+		return &SchemaError{Issues: v.issues}
+	}
+	return nil
+}
+
+// schemaValidator collects issues while walking a schema and its definitions.
+type schemaValidator struct {
+	defs   map[string]schemaNode
+	issues []SchemaIssue
+}
+
+// definition checks an entry of the root's definitions. The shim registers each one as a
+// named DynamicGenerationSchema, which only objects and string enums can be.
+func (v *schemaValidator) definition(name string, def schemaNode, pointer string) {
+	switch {
+	case len(def.Properties) == 0 && def.AnyOf == nil:
+		v.issues = append(v.issues, SchemaIssue{Pointer: pointer, Message: "definition must be an object or a string enum"})
+	case def.Name != "" && def.Name != name:
+		v.issues = append(v.issues, SchemaIssue{Pointer: pointer, Message: fmt.Sprintf("name %q differs from the definition key %q", def.Name, name)})
+	}
+	v.node(def, pointer, false)
+}
+
+func (v *schemaValidator) node(node schemaNode, pointer string, root bool) {
+	report := func(format string, args ...any) {
+		v.issues = append(v.issues, SchemaIssue{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if node.Definitions != nil && !root {
+		report("definitions are only allowed at the root")
+	}
+	if node.Ref != "" {
+		rest := node
+		rest.Ref, rest.Description, rest.Definitions = "", "", nil
+		if !reflect.DeepEqual(rest, schemaNode{}) {
+			report("$ref cannot be combined with other keywords")
+		}
+		if name, ok := definitionName(node.Ref); !ok {
+			report("$ref %q must have the form %s<name>", node.Ref, definitionsPrefix)
+		} else if _, ok := v.defs[name]; !ok {
+			report("$ref %q refers to an unknown definition", node.Ref)
+		}
+		return
+	}
+
+	isObject := len(node.Properties) > 0
+	isString := !isObject && (node.Type == "" || node.Type == "string")
+	isNumeric := !isObject && (node.Type == "integer" || node.Type == "number" || node.Type == "double")
+	switch node.Type {
+	case "", "string", "integer", "number", "double", "boolean", "array":
+		if isObject && node.Type != "" {
+			report("type %q cannot have properties", node.Type)
+		}
+	case "object":
+		if !isObject {
+			report("object requires properties")
+		}
+	default:
+		report("unknown type %q", node.Type)
+	}
+
+	if node.Type == "array" {
+		if node.Items == nil {
+			report("array requires items")
+		}
+	} else {
+		if node.Items != nil {
+			report("items only applies to arrays")
+		}
+		if node.MinimumElements != nil || node.MaximumElements != nil {
+			report("minimumElements and maximumElements only apply to arrays")
+		}
+	}
+	if minimum := node.MinimumElements; minimum != nil && *minimum < 0 {
+		report("minimumElements %d is negative", *minimum)
+	}
+	if maximum := node.MaximumElements; maximum != nil && *maximum < 0 {
+		report("maximumElements %d is negative", *maximum)
+	}
+	if node.MinimumElements != nil && node.MaximumElements != nil && *node.MinimumElements > *node.MaximumElements {
+		report("minimumElements %d exceeds maximumElements %d", *node.MinimumElements, *node.MaximumElements)
+	}
+
+	if node.AnyOf != nil {
+		if !isString {
+			report("anyOf only applies to strings")
+		} else if len(node.AnyOf) == 0 {
+			report("anyOf must list at least one choice")
+		}
+	}
+
+	if node.Minimum != nil || node.Maximum != nil {
+		if !isNumeric {
+			report("minimum and maximum only apply to integers and numbers")
+		} else if node.Type == "integer" {
+			for _, bound := range []*float64{node.Minimum, node.Maximum} {
+				if bound != nil && *bound != math.Trunc(*bound) {
+					report("integer bound %v is not a whole number", *bound)
+				}
+			}
+		}
+	}
+	if node.Minimum != nil && node.Maximum != nil && *node.Minimum > *node.Maximum {
+		report("minimum %v exceeds maximum %v", *node.Minimum, *node.Maximum)
+	}
+
+	if node.Pattern != "" {
+		if !isString {
+			report("pattern only applies to strings")
+		} else if node.AnyOf != nil && node.Const == nil {
+			report("pattern cannot be combined with anyOf")
+		} else if _, err := compilePattern(node.Pattern); err != nil {
+			report("pattern %q does not compile: %v", node.Pattern, err)
+		}
+	}
+	if node.Const != nil {
+		switch {
+		case !isString:
+			report("const only applies to strings")
+		case node.AnyOf != nil || node.Pattern != "":
+			report("const cannot be combined with anyOf or pattern")
+		}
+	}
+
+	seen := make(map[string]bool, len(node.Properties))
+	for i, prop := range node.Properties {
+		propPointer := pointer + "/properties/" + strconv.Itoa(i)
+		switch {
+		case prop.Name == "":
+			v.issues = append(v.issues, SchemaIssue{Pointer: propPointer, Message: "property name must not be empty"})
+		case seen[prop.Name]:
+			v.issues = append(v.issues, SchemaIssue{Pointer: propPointer, Message: fmt.Sprintf("duplicate property %q", prop.Name)})
+		}
+		seen[prop.Name] = true
+		v.node(prop.Schema, propPointer+"/schema", false)
+	}
+	if node.Items != nil {
+		v.node(*node.Items, pointer+"/items", false)
+	}
+}
+
+// compilePattern compiles a string pattern so that it must match the whole value, as
+// GenerationGuide.pattern does.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
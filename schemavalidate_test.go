@@ -0,0 +1,138 @@
+package fundament
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaValidateReportsEveryIssue(t *testing.T) {
+	raw := `{"name":"Plan","properties":[
+		{"name":"when","schema":{"type":"date"}},
+		{"name":"stops","schema":{"type":"array"}},
+		{"name":"count","schema":{"type":"integer","anyOf":["1","2"]}},
+		{"name":"tags","schema":{"type":"array","items":{"type":"string"},"minimumElements":4,"maximumElements":2}},
+		{"name":"when","schema":{"type":"boolean"}},
+		{"name":"","schema":{"type":"string"}},
+		{"name":"nested","schema":{"type":"array","items":{"name":"Item","properties":[{"name":"x","schema":{"type":"float"}}]}}}
+	]}`
+	_, err := SchemaFromRawJSON([]byte(raw))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+	want := []SchemaIssue{
+		{Pointer: "/properties/0/schema", Message: `unknown type "date"`},
+		{Pointer: "/properties/1/schema", Message: "array requires items"},
+		{Pointer: "/properties/2/schema", Message: "anyOf only applies to strings"},
+		{Pointer: "/properties/3/schema", Message: "minimumElements 4 exceeds maximumElements 2"},
+		{Pointer: "/properties/4", Message: `duplicate property "when"`},
+		{Pointer: "/properties/5", Message: "property name must not be empty"},
+		{Pointer: "/properties/6/schema/items/properties/0/schema", Message: `unknown type "float"`},
+	}
+	if !reflect.DeepEqual(schemaErr.Issues, want) {
+		t.Fatalf("issues = %+v\nwant %+v", schemaErr.Issues, want)
+	}
+}
+
+func TestSchemaValidateShapes(t *testing.T) {
+	cases := []struct {
+		raw   string
+		issue string
+	}{
+		{`{"type":"object"}`, "(root): object requires properties"},
+		{`{"type":"string","properties":[{"name":"a","schema":{"type":"string"}}]}`, `(root): type "string" cannot have properties`},
+		{`{"type":"string","anyOf":[]}`, "(root): anyOf must list at least one choice"},
+		{`{"type":"string","items":{"type":"string"}}`, "(root): items only applies to arrays"},
+		{`{"type":"integer","minimumElements":1}`, "(root): minimumElements and maximumElements only apply to arrays"},
+		{`{"type":"array","items":{"type":"string"},"maximumElements":-1}`, "(root): maximumElements -1 is negative"},
+		{`{"type":"integer","minimum":5,"maximum":1}`, "(root): minimum 5 exceeds maximum 1"},
+		{`{"type":"integer","minimum":0.5}`, "(root): integer bound 0.5 is not a whole number"},
+		{`{"type":"string","maximum":3}`, "(root): minimum and maximum only apply to integers and numbers"},
+		{`{"type":"integer","pattern":"a+"}`, "(root): pattern only applies to strings"},
+		{`{"type":"string","pattern":"(a"}`, "(root): pattern \"(a\" does not compile: error parsing regexp: missing closing ): `^(?:(a)$`"},
+		{`{"type":"string","const":"a","anyOf":["a"]}`, "(root): const cannot be combined with anyOf or pattern"},
+		{`{"type":"string","anyOf":["a","b"],"pattern":"a"}`, "(root): pattern cannot be combined with anyOf"},
+		{`{"anyOf":["a"],"pattern":"a"}`, "(root): pattern cannot be combined with anyOf"},
+		{`{"type":"boolean","const":"true"}`, "(root): const only applies to strings"},
+	}
+	for _, tc := range cases {
+		err := (Schema{raw: json.RawMessage(tc.raw)}).Validate()
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) || len(schemaErr.Issues) != 1 || schemaErr.Issues[0].String() != tc.issue {
+			t.Errorf("%s: expected %q, got %v", tc.raw, tc.issue, err)
+		}
+	}
+
+	valid := []string{
+		`{"type":"string"}`,
+		`{"anyOf":["a","b"]}`,
+		`{"type":"object","name":"X","properties":[{"name":"ok","schema":{"type":"boolean"}}]}`,
+		`{"type":"array","items":{"type":"integer"},"minimumElements":1,"maximumElements":1}`,
+		`{"type":"double","minimum":-1.5,"maximum":1.5}`,
+		`{"type":"string","pattern":"\\d{4}"}`,
+		`{"const":""}`,
+	}
+	for _, raw := range valid {
+		if _, err := SchemaFromRawJSON([]byte(raw)); err != nil {
+			t.Errorf("%s: unexpected error %v", raw, err)
+		}
+	}
+}
+
+func TestSchemaValidateReferences(t *testing.T) {
+	valid := `{"name":"Thread","properties":[
+		{"name":"root","schema":{"$ref":"#/definitions/Comment","description":"First post"}},
+		{"name":"mood","schema":{"$ref":"#/definitions/Mood"}}
+	],"definitions":{
+		"Comment":{"name":"Comment","properties":[
+			{"name":"text","schema":{"type":"string"}},
+			{"name":"replies","schema":{"type":"array","items":{"$ref":"#/definitions/Comment"}}}
+		]},
+		"Mood":{"anyOf":["calm","heated"]}
+	}}`
+	if _, err := SchemaFromRawJSON([]byte(valid)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	nonEmpty := `{"$ref":"#/definitions/Node","definitions":{
+		"Node":{"name":"Node","properties":[
+			{"name":"children","schema":{"type":"array","items":{"$ref":"#/definitions/Node"},"minimumElements":1}}
+		]}
+	}}`
+	_, err := SchemaFromRawJSON([]byte(nonEmpty))
+	var nonEmptyErr *SchemaError
+	if !errors.As(err, &nonEmptyErr) || len(nonEmptyErr.Issues) != 1 || nonEmptyErr.Issues[0].Pointer != "/definitions/Node" {
+		t.Fatalf("expected a recursion through a non-empty array to be rejected, got %v", err)
+	}
+
+	raw := `{"name":"Root","properties":[
+		{"name":"a","schema":{"$ref":"#/definitions/Missing"}},
+		{"name":"b","schema":{"$ref":"Address"}},
+		{"name":"c","schema":{"$ref":"#/definitions/Address","type":"string"}},
+		{"name":"d","schema":{"name":"Inner","properties":[{"name":"x","schema":{"type":"integer"}}],"definitions":{}}}
+	],"definitions":{
+		"Address":{"name":"Place","properties":[{"name":"owner","schema":{"$ref":"#/definitions/Person"}}]},
+		"Person":{"name":"Person","properties":[{"name":"home","schema":{"$ref":"#/definitions/Address"}}]},
+		"Text":{"type":"string"}
+	}}`
+	_, err = SchemaFromRawJSON([]byte(raw))
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("expected *SchemaError, got %v", err)
+	}
+	want := []SchemaIssue{
+		{Pointer: "/properties/0/schema", Message: `$ref "#/definitions/Missing" refers to an unknown definition`},
+		{Pointer: "/properties/1/schema", Message: `$ref "Address" must have the form #/definitions/<name>`},
+		{Pointer: "/properties/2/schema", Message: "$ref cannot be combined with other keywords"},
+		{Pointer: "/properties/3/schema", Message: "definitions are only allowed at the root"},
+		{Pointer: "/definitions/Address", Message: `name "Place" differs from the definition key "Address"`},
+		{Pointer: "/definitions/Text", Message: "definition must be an object or a string enum"},
+		{Pointer: "/definitions/Address", Message: `definition "Address" contains itself through required properties; refer to it from an array that may be empty instead`},
+		{Pointer: "/definitions/Person", Message: `definition "Person" contains itself through required properties; refer to it from an array that may be empty instead`},
+	}
+	if !reflect.DeepEqual(schemaErr.Issues, want) {
+		t.Fatalf("issues = %+v\nwant %+v", schemaErr.Issues, want)
+	}
+}
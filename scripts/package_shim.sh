@@ -46,6 +46,23 @@ else
 	log "warning: 'codesign' not found; skipping signature inspection"
 fi
 
+if command -v nm >/dev/null 2>&1; then
+	exported="$(nm -gU "${tmp_dylib}" 2>/dev/null || true)"
+	missing=()
+	for symbol in $(grep -o 'shimloader.Register("[a-z_]*"' "${PROJECT_ROOT}/internal/native/native_darwin.go" | sed 's/.*("\(.*\)"/\1/'); do
+		if ! grep -q " T _${symbol}\$" <<<"${exported}"; then
+			missing+=("${symbol}")
+		fi
+	done
+	if (( ${#missing[@]} > 0 )); then
+		log "error: ${DYLIB_NAME} does not export ${missing[*]}"
+		log "hint: every symbol registered in internal/native/native_darwin.go must be @_cdecl-exported"
+		exit 1
+	fi
+else
+	log "warning: 'nm' not found; skipping export check"
+fi
+
 install_name="@rpath/${DYLIB_NAME}"
 if command -v install_name_tool >/dev/null 2>&1; then
 	install_name_tool -id "${install_name}" "${tmp_dylib}"
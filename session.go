@@ -5,122 +5,430 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/domano/fundament/internal/native"
-)
-
-// Hooks around the native bindings so tests can stub behaviour without cgo.
-var (
-	nativeSessionCreate            = native.SessionCreate
-	nativeSessionDestroy           = native.SessionDestroy
-	nativeSessionRespond           = native.SessionRespond
-	nativeSessionRespondStructured = native.SessionRespondStructured
-	nativeSessionStream            = native.SessionStream
 )
 
 // SessionOptions configure how a Session is created.
 type SessionOptions struct {
 	Instructions string
+	// Backend serves the session; nil selects NativeBackend.
+	Backend Backend
+	// Transcript restores an earlier conversation. Its instructions entry, if any, is used when
+	// Instructions is empty. The backend must implement TranscriptBackend.
+	Transcript *Transcript
+	// ContextPolicy, when set, rolls the conversation over before it outgrows the context window.
+	ContextPolicy *ContextPolicy
+	// Prewarm starts Session.Prewarm in the background once the session is created.
+	// Wait on Session.Prewarmed to learn when it finished.
+	Prewarm bool
+	// OnBusy decides what happens to a call made while another call is in progress.
+	// The default, BusyQueue, serves calls one at a time in arrival order.
+	OnBusy BusyMode
+	// Defaults apply to every call before the call's own options, so a per-call option
+	// overrides the same field set here, and a per-call sampling choice replaces a conflicting
+	// one set here; see Session.EffectiveOptions. Within each list later options win. Presets
+	// such as Precise can be combined with single options, e.g. {Precise(), WithMaxTokens(200)}.
+	Defaults []GenerationOption
 }
 
-// Session wraps a native session handle.
+// Session wraps a conversation hosted by a Backend. Calls are served one at a time;
+// see SessionOptions.OnBusy.
 type Session struct {
-	mu      sync.RWMutex
-	ref     native.SessionRef
-	closed  bool
-	instr   string
-	created time.Time
+	mu       sync.RWMutex
+	backend  BackendSession
+	factory  Backend
+	policy   *ContextPolicy
+	queue    callQueue
+	onBusy   BusyMode
+	defaults []GenerationOption
+	closed   bool
+	instr    string
+	created  time.Time
+
+	// active counts calls in flight; drained is closed when it drops to zero after Close.
+	// halt is cancelled to abort them.
+	active    int
+	drained   chan struct{}
+	halt      context.Context
+	haltCalls context.CancelFunc
+
+	leak *leakRecord // set in debug mode
+
+	tmu        sync.Mutex
+	transcript Transcript
+
+	prewarmed chan error
 }
 
-// NewSession creates a new LanguageModelSession bound to the default SystemLanguageModel.
+// NewSession creates a new LanguageModelSession bound to the default SystemLanguageModel,
+// or to opts.Backend when set.
 func NewSession(opts SessionOptions) (*Session, error) {
-	ref, err := nativeSessionCreate(opts.Instructions)
+	backend := opts.Backend
+	if backend == nil {
+		backend = NativeBackend()
+	}
+	var transcript Transcript
+	if opts.Transcript != nil {
+		if err := opts.Transcript.Validate(); err != nil {
+			return nil, err
+		}
+		transcript = opts.Transcript.clone()
+	}
+	instructions := opts.Instructions
+	switch existing := transcript.Instructions(); {
+	case instructions == "":
+		instructions = existing
+	case existing == "":
+		transcript.Entries = append([]TranscriptEntry{{Kind: EntryInstructions, Text: instructions}}, transcript.Entries...)
+	case existing != instructions:
+		return nil, errors.New("fundament: instructions conflict with the transcript's instructions entry")
+	}
+
+	defaults := append([]GenerationOption(nil), opts.Defaults...)
+	if err := resolveGenerationOptions(defaults).Validate(); err != nil {
+		return nil, err
+	}
+
+	var (
+		bs  BackendSession
+		err error
+	)
+	if opts.Transcript != nil {
+		tb, ok := backend.(TranscriptBackend)
+		if !ok {
+			return nil, errTranscriptUnsupported
+		}
+		bs, err = tb.NewSessionFromTranscript(transcript.clone())
+	} else {
+		bs, err = backend.NewSession(instructions)
+	}
 	if err != nil {
 		return nil, err
 	}
-	return &Session{
-		ref:     ref,
-		instr:   opts.Instructions,
-		created: time.Now(),
-	}, nil
+	session := &Session{
+		backend:    bs,
+		factory:    backend,
+		policy:     opts.ContextPolicy,
+		onBusy:     opts.OnBusy,
+		defaults:   defaults,
+		instr:      instructions,
+		created:    time.Now(),
+		transcript: transcript,
+		prewarmed:  make(chan error, 1),
+	}
+	session.halt, session.haltCalls = context.WithCancel(context.Background())
+	session.track()
+	if opts.Prewarm {
+		go func() {
+			session.prewarmed <- session.Prewarm(context.Background(), "")
+			close(session.prewarmed)
+		}()
+	} else {
+		close(session.prewarmed)
+	}
+	return session, nil
 }
 
-// Close releases native resources.
-func (s *Session) Close() error {
-	s.mu.Lock()
-	if s.closed {
-		s.mu.Unlock()
+// Prewarm asks the backend to load model resources so the first response starts sooner.
+// promptPrefix, if known, lets the model cache the beginning of the next prompt.
+// It is a no-op for backends whose sessions do not implement Prewarmer.
+func (s *Session) Prewarm(ctx context.Context, promptPrefix string) (err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, done, err := s.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { err = done(err) }()
+	p, ok := s.current().(Prewarmer)
+	if !ok {
 		return nil
 	}
-	ref := s.ref
-	s.ref = nil
-	s.closed = true
+	return p.Prewarm(ctx, promptPrefix)
+}
+
+// current returns the backend session serving the conversation. It is only replaced by a
+// context rollover and only released once no call is in flight.
+func (s *Session) current() BackendSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.backend
+}
+
+// Prewarmed reports the result of the background prewarm requested by SessionOptions.Prewarm.
+// The channel delivers at most one error and is then closed; without the option it is already closed.
+func (s *Session) Prewarmed() <-chan error {
+	return s.prewarmed
+}
+
+// Transcript returns a copy of the conversation so far. Only completed calls are recorded.
+func (s *Session) Transcript() Transcript {
+	s.tmu.Lock()
+	defer s.tmu.Unlock()
+	return s.transcript.clone()
+}
+
+// Fork returns an independent Session whose history is a copy of the first atEntry transcript
+// entries. The instructions entry is always kept. atEntry must be the index of a prompt entry
+// or the length of the transcript, so that the fork never holds a prompt without its
+// response; other indexes return an error. Forking at a prompt drops that prompt and
+// everything after it, which suits "regenerate" and "edit message" flows.
+//
+// Backends that implement TranscriptBackend restore the history directly. Other backends
+// rebuild it by sending the prompts again with the session defaults, so the fork's responses
+// are generated anew and may differ from the original ones; its transcript records the new
+// responses. Replaying requires every prompt to be followed by its response and does not
+// support tool entries.
+func (s *Session) Fork(atEntry int) (*Session, error) {
+	s.mu.RLock()
+	closed := s.closed
+	s.mu.RUnlock()
+	if closed {
+		return nil, ErrSessionClosed
+	}
+	transcript := s.Transcript()
+	if atEntry < 0 || atEntry > len(transcript.Entries) {
+		return nil, fmt.Errorf("fundament: fork entry %d out of range [0, %d]", atEntry, len(transcript.Entries))
+	}
+	if atEntry == 0 && transcript.Instructions() != "" {
+		atEntry = 1
+	}
+	if atEntry < len(transcript.Entries) && transcript.Entries[atEntry].Kind != EntryPrompt {
+		return nil, fmt.Errorf("fundament: fork entry %d is a %s entry; fork at a prompt entry or the end so that no prompt loses its response", atEntry, transcript.Entries[atEntry].Kind)
+	}
+	transcript.Entries = transcript.Entries[:atEntry]
+	var (
+		bs  BackendSession
+		err error
+	)
+	if tb, ok := s.factory.(TranscriptBackend); ok {
+		bs, err = tb.NewSessionFromTranscript(transcript.clone())
+	} else {
+		bs, transcript, err = s.replay(transcript)
+	}
+	if err != nil {
+		return nil, err
+	}
+	prewarmed := make(chan error)
+	close(prewarmed)
+	forked := &Session{
+		backend:    bs,
+		factory:    s.factory,
+		policy:     s.policy,
+		onBusy:     s.onBusy,
+		defaults:   s.defaults,
+		instr:      s.instr,
+		created:    time.Now(),
+		transcript: transcript,
+		prewarmed:  prewarmed,
+	}
+	forked.halt, forked.haltCalls = context.WithCancel(context.Background())
+	forked.track()
+	return forked, nil
+}
+
+// replay rebuilds t on a fresh backend session by sending its prompts again. It returns the
+// session and a transcript holding the responses it generated.
+func (s *Session) replay(t Transcript) (_ BackendSession, _ Transcript, err error) {
+	bs, err := s.factory.NewSession(t.Instructions())
+	if err != nil {
+		return nil, Transcript{}, err
+	}
+	defer func() {
+		if err != nil {
+			bs.Close()
+			err = fmt.Errorf("fundament: fork: %w", err)
+		}
+	}()
+	options := s.EffectiveOptions()
+	options.StopSequences, options.MaxCharacters = nil, nil
+
+	replayed := Transcript{Entries: make([]TranscriptEntry, 0, len(t.Entries))}
+	entries := t.Entries
+	if len(entries) > 0 && entries[0].Kind == EntryInstructions {
+		replayed.Entries = append(replayed.Entries, entries[0])
+		entries = entries[1:]
+	}
+	for ; len(entries) > 0; entries = entries[2:] {
+		switch {
+		case entries[0].Kind != EntryPrompt:
+			return nil, Transcript{}, fmt.Errorf("cannot replay a %s entry", entries[0].Kind)
+		case len(entries) == 1:
+			return nil, Transcript{}, fmt.Errorf("cannot replay prompt %q without its response", entries[0].Text)
+		}
+		prompt, answer := entries[0], entries[1]
+		switch answer.Kind {
+		case EntryResponse:
+			resp, err := bs.Respond(context.Background(), prompt.Text, options)
+			if err != nil {
+				return nil, Transcript{}, err
+			}
+			answer.Text = resp.Text
+		case EntryStructuredResponse:
+			schema, err := SchemaFromRawJSON(answer.Schema)
+			if err != nil {
+				return nil, Transcript{}, err
+			}
+			resp, err := bs.RespondStructured(context.Background(), prompt.Text, schema, options)
+			if err != nil {
+				return nil, Transcript{}, err
+			}
+			answer.JSON = resp.JSON
+		default:
+			return nil, Transcript{}, fmt.Errorf("cannot replay a %s entry", answer.Kind)
+		}
+		replayed.Entries = append(replayed.Entries, prompt, answer)
+	}
+	return bs, replayed, nil
+}
+
+// swap installs bs, which holds transcript, as the backend session and closes the one it
+// replaces. Callers hold the call queue.
+func (s *Session) swap(bs BackendSession, transcript Transcript) {
+	s.mu.Lock()
+	replaced := s.backend
+	s.backend = bs
+	s.tmu.Lock()
+	s.transcript = transcript
+	s.tmu.Unlock()
 	s.mu.Unlock()
-	if ref != nil {
-		nativeSessionDestroy(ref)
+	replaced.Close()
+}
+
+// resync rebuilds the backend session from the transcript after the session cut a response
+// short: the backend kept either the whole response or, when the cut abandoned the call, none
+// of it. Backends without TranscriptBackend, or that fail to restore, keep their session, which
+// stays usable but remembers a different version of the turn.
+func (s *Session) resync() {
+	tb, ok := s.factory.(TranscriptBackend)
+	if !ok {
+		return
 	}
-	return nil
+	transcript := s.Transcript()
+	bs, err := tb.NewSessionFromTranscript(transcript.clone())
+	if err != nil {
+		return
+	}
+	s.swap(bs, transcript)
+}
+
+// record appends a completed turn to the transcript.
+func (s *Session) record(entries ...TranscriptEntry) {
+	s.tmu.Lock()
+	defer s.tmu.Unlock()
+	s.transcript.Entries = append(s.transcript.Entries, entries...)
 }
 
 // Response captures the result of a Respond call.
 type Response struct {
 	Text string
+	// Backend names the route that served the response when the session uses a Router.
+	Backend string
+	// Warnings lists generation options the backend could not honour.
+	Warnings []string
+	// FinishReason is set when WithStopSequences or WithMaxCharacters cut the response short.
+	FinishReason FinishReason
 }
 
 // StructuredResponse captures a structured result in JSON form.
 type StructuredResponse struct {
 	JSON json.RawMessage
+	// Backend names the route that served the response when the session uses a Router.
+	Backend string
+	// Warnings lists generation options the backend could not honour.
+	Warnings []string
 }
 
 // Respond performs a single-shot generation call.
-func (s *Session) Respond(ctx context.Context, prompt string, opts ...GenerationOption) (Response, error) {
-	if ctx != nil {
-		if err := ctx.Err(); err != nil {
-			return Response{}, err
-		}
+func (s *Session) Respond(ctx context.Context, prompt string, opts ...GenerationOption) (_ Response, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
 	}
-	_, blob, err := encodeGenerationOptions(opts)
+	options, err := s.callOptions(opts)
 	if err != nil {
 		return Response{}, err
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	if s.closed || s.ref == nil {
-		return Response{}, errors.New("fundament: session has been closed")
+	ctx, done, err := s.begin(ctx)
+	if err != nil {
+		return Response{}, err
 	}
-	text, err := nativeSessionRespond(s.ref, prompt, blob)
+	defer func() { err = done(err) }()
+	if err := s.acquire(ctx); err != nil {
+		return Response{}, err
+	}
+	defer s.queue.leave()
+	if err := s.ensureContext(ctx, prompt, options); err != nil {
+		return Response{}, err
+	}
+	resp, err := s.current().Respond(ctx, prompt, options)
 	if err != nil {
 		return Response{}, err
 	}
-	return Response{Text: text}, nil
+	text, reason := applyStops(resp.Text, options)
+	s.record(TranscriptEntry{Kind: EntryPrompt, Text: prompt}, TranscriptEntry{Kind: EntryResponse, Text: text})
+	if reason != "" {
+		resp.Text, resp.FinishReason = text, reason
+		s.resync()
+	}
+	return resp, nil
 }
 
-// RespondStructured generates content guided by a schema, returning raw JSON.
-func (s *Session) RespondStructured(ctx context.Context, prompt string, schema Schema, opts ...GenerationOption) (StructuredResponse, error) {
-	if ctx != nil {
-		if err := ctx.Err(); err != nil {
-			return StructuredResponse{}, err
-		}
+// RespondStructured generates content guided by a schema, returning raw JSON. The JSON is
+// checked with Schema.CheckResponse, and a mismatch is returned as a *SchemaViolationError
+// unless WithoutResponseValidation is set.
+func (s *Session) RespondStructured(ctx context.Context, prompt string, schema Schema, opts ...GenerationOption) (_ StructuredResponse, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return StructuredResponse{}, err
 	}
 	if len(schema.raw) == 0 {
 		return StructuredResponse{}, errors.New("fundament: schema must not be empty")
 	}
-	_, blob, err := encodeGenerationOptions(opts)
+	options, err := s.callOptions(opts)
 	if err != nil {
 		return StructuredResponse{}, err
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	if s.closed || s.ref == nil {
-		return StructuredResponse{}, errors.New("fundament: session has been closed")
+	ctx, done, err := s.begin(ctx)
+	if err != nil {
+		return StructuredResponse{}, err
+	}
+	defer func() { err = done(err) }()
+	if err := s.acquire(ctx); err != nil {
+		return StructuredResponse{}, err
 	}
-	text, err := nativeSessionRespondStructured(s.ref, prompt, string(schema.raw), blob)
+	defer s.queue.leave()
+	if err := s.ensureContext(ctx, prompt+schema.String(), options); err != nil {
+		return StructuredResponse{}, err
+	}
+	// Cutting JSON short would break it, so output limits only apply to text responses.
+	options.StopSequences, options.MaxCharacters = nil, nil
+	validate := !options.SkipResponseValidation
+	options.SkipResponseValidation = false
+	resp, err := s.current().RespondStructured(ctx, prompt, schema, options)
 	if err != nil {
 		return StructuredResponse{}, err
 	}
-	return StructuredResponse{JSON: json.RawMessage(text)}, nil
+	s.record(
+		TranscriptEntry{Kind: EntryPrompt, Text: prompt},
+		TranscriptEntry{Kind: EntryStructuredResponse, JSON: append(json.RawMessage(nil), resp.JSON...), Schema: schema.Raw()},
+	)
+	if validate {
+		if err := schema.CheckResponse(resp.JSON); err != nil {
+			return StructuredResponse{}, err
+		}
+	}
+	return resp, nil
 }
 
 // RespondStructuredInto populates target with the structured response.
@@ -140,47 +448,102 @@ type StreamChunk struct {
 	Text  string
 	Final bool
 	Err   error
+	// Backend names the route that produced the chunk when the session uses a Router.
+	Backend string
+	// Warnings lists generation options the backend could not honour. It is set on the final chunk.
+	Warnings []string
+	// FinishReason is set on the final chunk when WithStopSequences or WithMaxCharacters cut the
+	// response short.
+	FinishReason FinishReason
 }
 
 // RespondStream streams a response into a channel. The returned channel is closed when streaming completes or on error.
+// With BusyFail it returns ErrSessionBusy instead of queueing behind a call in progress.
 func (s *Session) RespondStream(ctx context.Context, prompt string, opts ...GenerationOption) (<-chan StreamChunk, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	_, blob, err := encodeGenerationOptions(opts)
+	options, err := s.callOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	callCtx, done, err := s.begin(ctx)
 	if err != nil {
 		return nil, err
 	}
+	// Take a place in line now so streams keep their call order, but wait for the turn in the background.
+	turn, err := s.queue.enter(s.onBusy)
+	if err != nil {
+		return nil, done(err)
+	}
 
 	out := make(chan StreamChunk, 8)
 	go func() {
 		defer close(out)
-		s.mu.RLock()
-		if s.closed || s.ref == nil {
-			s.mu.RUnlock()
+		err := done(s.stream(callCtx, turn, prompt, options, out))
+		if err == nil || errors.Is(ctx.Err(), context.Canceled) {
 			return
 		}
-		ref := s.ref
-		s.mu.RUnlock()
-		err := nativeSessionStream(ref, prompt, blob, func(chunk string, final bool) {
-			select {
-			case <-ctx.Done():
-				return
-			case out <- StreamChunk{Text: chunk, Final: final}:
-			}
-		})
-		if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		select {
+		case <-ctx.Done():
+		case out <- StreamChunk{Err: err, Final: true}:
+		}
+	}()
+	return out, nil
+}
+
+// stream runs a RespondStream call once it is the caller's turn.
+func (s *Session) stream(ctx context.Context, turn chan struct{}, prompt string, options GenerationOptions, out chan<- StreamChunk) error {
+	if err := s.queue.wait(ctx, turn); err != nil {
+		return err
+	}
+	defer s.queue.leave()
+	if err := s.ensureContext(ctx, prompt, options); err != nil {
+		return err
+	}
+	// Stop sequences and length limits end the call early through its own context, so the
+	// backend stops generating while the session stays usable.
+	scan := newStopScanner(options)
+	genCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	var (
+		text     strings.Builder
+		finished bool
+	)
+	err := s.current().RespondStream(genCtx, prompt, options, func(chunk StreamChunk) {
+		if finished {
 			return
 		}
-		if err != nil {
-			select {
-			case <-ctx.Done():
-			case out <- StreamChunk{Err: err, Final: true}:
+		if scan != nil {
+			var done bool
+			chunk.Text, done = scan.push(chunk.Text)
+			if chunk.Final && !done {
+				chunk.Text += scan.flush()
+			}
+			if done {
+				chunk.Final, chunk.FinishReason = true, scan.reason
+				finished = true
+				stop()
+			}
+			if chunk.Text == "" && !chunk.Final {
+				return
 			}
+		}
+		text.WriteString(chunk.Text)
+		select {
+		case <-ctx.Done():
 			return
+		case out <- chunk:
 		}
-	}()
-	return out, nil
+	})
+	if err != nil && !finished {
+		return err
+	}
+	s.record(TranscriptEntry{Kind: EntryPrompt, Text: prompt}, TranscriptEntry{Kind: EntryResponse, Text: text.String()})
+	if finished {
+		s.resync()
+	}
+	return nil
 }
 
 // Instructions returns the initial instructions configured for the session.
@@ -7,86 +7,97 @@ import (
 	"sync"
 	"testing"
 	"time"
-	"unsafe"
-
-	"github.com/domano/fundament/internal/native"
 )
 
-func withSessionHooks(
-	create func(string) (native.SessionRef, error),
-	destroy func(native.SessionRef),
-	respond func(native.SessionRef, string, string) (string, error),
-	respondStructured func(native.SessionRef, string, string, string) (string, error),
-	stream func(native.SessionRef, string, string, native.StreamCallback) error,
-) func() {
-	prevCreate := nativeSessionCreate
-	prevDestroy := nativeSessionDestroy
-	prevRespond := nativeSessionRespond
-	prevRespondStructured := nativeSessionRespondStructured
-	prevStream := nativeSessionStream
+// stubBackend is a Backend whose behaviour is supplied per test.
+type stubBackend struct {
+	create            func(string) error
+	respond           func(string, GenerationOptions) (string, error)
+	respondStructured func(string, Schema, GenerationOptions) (string, error)
+	stream            func(string, GenerationOptions, func(StreamChunk)) error
+	// streamCtx, when set, replaces stream for tests that watch the call's context.
+	streamCtx func(context.Context, func(StreamChunk)) error
+	// block, when set, holds Respond until it is closed or the call's context ends.
+	block  <-chan struct{}
+	closes int
+}
 
-	if create != nil {
-		nativeSessionCreate = create
-	}
-	if destroy != nil {
-		nativeSessionDestroy = destroy
+func (b *stubBackend) NewSession(instructions string) (BackendSession, error) {
+	if b.create != nil {
+		if err := b.create(instructions); err != nil {
+			return nil, err
+		}
 	}
-	if respond != nil {
-		nativeSessionRespond = respond
+	return &stubSession{backend: b}, nil
+}
+
+func (b *stubBackend) CheckAvailability() (Availability, error) {
+	return Availability{State: AvailabilityReady}, nil
+}
+
+type stubSession struct {
+	backend *stubBackend
+}
+
+func (s *stubSession) Respond(ctx context.Context, prompt string, opts GenerationOptions) (Response, error) {
+	if s.backend.block != nil {
+		select {
+		case <-s.backend.block:
+		case <-ctx.Done():
+			return Response{}, ctx.Err()
+		}
 	}
-	if respondStructured != nil {
-		nativeSessionRespondStructured = respondStructured
+	if s.backend.respond == nil {
+		return Response{}, errors.New("respond not stubbed")
 	}
-	if stream != nil {
-		nativeSessionStream = stream
+	text, err := s.backend.respond(prompt, opts)
+	return Response{Text: text}, err
+}
+
+func (s *stubSession) RespondStructured(_ context.Context, prompt string, schema Schema, opts GenerationOptions) (StructuredResponse, error) {
+	if s.backend.respondStructured == nil {
+		return StructuredResponse{}, errors.New("respondStructured not stubbed")
 	}
+	text, err := s.backend.respondStructured(prompt, schema, opts)
+	return StructuredResponse{JSON: json.RawMessage(text)}, err
+}
 
-	return func() {
-		nativeSessionCreate = prevCreate
-		nativeSessionDestroy = prevDestroy
-		nativeSessionRespond = prevRespond
-		nativeSessionRespondStructured = prevRespondStructured
-		nativeSessionStream = prevStream
+func (s *stubSession) RespondStream(ctx context.Context, prompt string, opts GenerationOptions, fn func(StreamChunk)) error {
+	if s.backend.streamCtx != nil {
+		return s.backend.streamCtx(ctx, fn)
+	}
+	if s.backend.stream == nil {
+		return errors.New("stream not stubbed")
 	}
+	return s.backend.stream(prompt, opts, fn)
 }
 
-func TestNewSessionAndRespond(t *testing.T) {
-	dummyRef := native.SessionRef(unsafe.Pointer(&struct{}{}))
+func (s *stubSession) Close() error {
+	s.backend.closes++
+	return nil
+}
 
-	restore := withSessionHooks(
-		func(instr string) (native.SessionRef, error) {
+func TestNewSessionAndRespond(t *testing.T) {
+	backend := &stubBackend{
+		create: func(instr string) error {
 			if instr != "test instructions" {
 				t.Fatalf("unexpected instructions %q", instr)
 			}
-			return dummyRef, nil
+			return nil
 		},
-		nil,
-		func(ref native.SessionRef, prompt, opts string) (string, error) {
-			if ref != dummyRef {
-				t.Fatalf("unexpected ref %+v", ref)
-			}
+		respond: func(prompt string, opts GenerationOptions) (string, error) {
 			if prompt != "ping" {
 				t.Fatalf("unexpected prompt %q", prompt)
 			}
-			// ensure generation options are encoded when provided
-			if opts == "" {
-				t.Fatal("expected options payload")
-			}
-			var payload map[string]any
-			if err := json.Unmarshal([]byte(opts), &payload); err != nil {
-				t.Fatalf("invalid options JSON: %v", err)
-			}
-			if payload["temperature"] != 0.5 {
-				t.Fatalf("expected temperature 0.5, got %v", payload["temperature"])
+			// ensure generation options are passed through when provided
+			if opts.Temperature == nil || *opts.Temperature != 0.5 {
+				t.Fatalf("expected temperature 0.5, got %v", opts.Temperature)
 			}
 			return "pong", nil
 		},
-		nil,
-		nil,
-	)
-	defer restore()
+	}
 
-	session, err := NewSession(SessionOptions{Instructions: "test instructions"})
+	session, err := NewSession(SessionOptions{Instructions: "test instructions", Backend: backend})
 	if err != nil {
 		t.Fatalf("NewSession error: %v", err)
 	}
@@ -101,6 +112,15 @@ func TestNewSessionAndRespond(t *testing.T) {
 	}
 }
 
+func TestNewSessionBackendError(t *testing.T) {
+	backend := &stubBackend{
+		create: func(string) error { return errors.New("boom") },
+	}
+	if _, err := NewSession(SessionOptions{Backend: backend}); err == nil {
+		t.Fatal("expected backend create error")
+	}
+}
+
 func TestRespondContextCancelled(t *testing.T) {
 	session := &Session{}
 
@@ -117,63 +137,51 @@ func TestRespondAfterClose(t *testing.T) {
 		closed: true,
 	}
 	_, err := session.Respond(context.Background(), "ping")
-	if err == nil {
-		t.Fatal("expected error when responding on closed session")
+	if !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
 	}
 }
 
 func TestSessionCloseIdempotent(t *testing.T) {
-	dummyRef := native.SessionRef(unsafe.Pointer(&struct{}{}))
-	var destroys int
-	restore := withSessionHooks(nil, func(ref native.SessionRef) {
-		if ref != dummyRef {
-			t.Fatalf("unexpected ref %v", ref)
-		}
-		destroys++
-	}, nil, nil, nil)
-	defer restore()
-
-	session := &Session{ref: dummyRef}
+	backend := &stubBackend{}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
 	if err := session.Close(); err != nil {
 		t.Fatalf("first close failed: %v", err)
 	}
 	if err := session.Close(); err != nil {
 		t.Fatalf("second close failed: %v", err)
 	}
-	if destroys != 1 {
-		t.Fatalf("expected destroy once, got %d", destroys)
+	if backend.closes != 1 {
+		t.Fatalf("expected close once, got %d", backend.closes)
 	}
 }
 
 func TestRespondStructuredInto(t *testing.T) {
-	dummyRef := native.SessionRef(unsafe.Pointer(&struct{}{}))
-	restore := withSessionHooks(
-		func(string) (native.SessionRef, error) { return dummyRef, nil },
-		nil,
-		nil,
-		func(ref native.SessionRef, prompt, schemaJSON, opts string) (string, error) {
-			if ref != dummyRef {
-				t.Fatalf("unexpected ref %v", ref)
-			}
+	backend := &stubBackend{
+		respondStructured: func(prompt string, schema Schema, _ GenerationOptions) (string, error) {
 			if prompt != "generate" {
 				t.Fatalf("unexpected prompt %q", prompt)
 			}
-			if schemaJSON == "" {
+			if schema.String() == "" {
 				t.Fatal("expected schema JSON")
 			}
 			return `{"message":"ok","value":42}`, nil
 		},
-		nil,
-	)
-	defer restore()
+	}
 
-	session, err := NewSession(SessionOptions{})
+	session, err := NewSession(SessionOptions{Backend: backend})
 	if err != nil {
 		t.Fatalf("NewSession error: %v", err)
 	}
 
 	schema, err := SchemaFromValue(map[string]any{
-		"type": "object",
+		"properties": []map[string]any{
+			{"name": "message", "schema": map[string]any{"type": "string"}},
+			{"name": "value", "schema": map[string]any{"type": "integer"}},
+		},
 	})
 	if err != nil {
 		t.Fatalf("SchemaFromValue error: %v", err)
@@ -199,23 +207,17 @@ func TestRespondStructuredRequiresSchema(t *testing.T) {
 }
 
 func TestRespondStream(t *testing.T) {
-	dummyRef := native.SessionRef(unsafe.Pointer(&struct{}{}))
 	var streamCalled bool
-	restore := withSessionHooks(
-		func(string) (native.SessionRef, error) { return dummyRef, nil },
-		nil,
-		nil,
-		nil,
-		func(ref native.SessionRef, prompt, opts string, cb native.StreamCallback) error {
+	backend := &stubBackend{
+		stream: func(prompt string, _ GenerationOptions, fn func(StreamChunk)) error {
 			streamCalled = true
-			cb("hello", false)
-			cb("world", true)
+			fn(StreamChunk{Text: "hello"})
+			fn(StreamChunk{Text: "world", Final: true})
 			return nil
 		},
-	)
-	defer restore()
+	}
 
-	session, err := NewSession(SessionOptions{})
+	session, err := NewSession(SessionOptions{Backend: backend})
 	if err != nil {
 		t.Fatalf("NewSession error: %v", err)
 	}
@@ -245,25 +247,19 @@ func TestRespondStream(t *testing.T) {
 }
 
 func TestConcurrentRespondCalls(t *testing.T) {
-	dummyRef := native.SessionRef(unsafe.Pointer(&struct{}{}))
 	var mu sync.Mutex
 	var prompts []string
-	restore := withSessionHooks(
-		func(string) (native.SessionRef, error) { return dummyRef, nil },
-		nil,
-		func(ref native.SessionRef, prompt, _ string) (string, error) {
+	backend := &stubBackend{
+		respond: func(prompt string, _ GenerationOptions) (string, error) {
 			time.Sleep(5 * time.Millisecond)
 			mu.Lock()
 			prompts = append(prompts, prompt)
 			mu.Unlock()
 			return prompt + "-ok", nil
 		},
-		nil,
-		nil,
-	)
-	defer restore()
+	}
 
-	session, err := NewSession(SessionOptions{})
+	session, err := NewSession(SessionOptions{Backend: backend})
 	if err != nil {
 		t.Fatalf("NewSession error: %v", err)
 	}
@@ -292,3 +288,22 @@ func TestConcurrentRespondCalls(t *testing.T) {
 		t.Fatalf("expected 3 prompts recorded, got %d", len(prompts))
 	}
 }
+
+func TestPrewarmWithoutPrewarmerIsNoop(t *testing.T) {
+	session, err := NewSession(SessionOptions{Backend: &stubBackend{}, Prewarm: true})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	select {
+	case err := <-session.Prewarmed():
+		if err != nil {
+			t.Fatalf("background prewarm error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("prewarm did not complete")
+	}
+	if err := session.Prewarm(context.Background(), "prefix"); err != nil {
+		t.Fatalf("Prewarm error: %v", err)
+	}
+}
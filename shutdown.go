@@ -0,0 +1,109 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSessionClosed is returned by calls made after Close or Shutdown, and by calls that Close
+// cancelled while they were in progress.
+var ErrSessionClosed = errors.New("fundament: session has been closed")
+
+// begin registers an in-flight call. The returned context is also cancelled when the session
+// stops its calls. done must be called exactly once with the call's result; it returns that
+// result with errors caused by the session stopping replaced by ErrSessionClosed.
+func (s *Session) begin(ctx context.Context) (context.Context, func(error) error, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, nil, ErrSessionClosed
+	}
+	s.active++
+	s.mu.Unlock()
+
+	callCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(s.halt, cancel)
+	done := func(err error) error {
+		stop()
+		cancel()
+		s.end()
+		if err != nil && ctx.Err() == nil && s.halt.Err() != nil {
+			return ErrSessionClosed
+		}
+		return err
+	}
+	return callCtx, done, nil
+}
+
+func (s *Session) end() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if s.active == 0 && s.drained != nil {
+		close(s.drained)
+		s.drained = nil
+	}
+}
+
+// stopAccepting marks the session closed and returns a channel that is closed once no call is
+// in flight.
+func (s *Session) stopAccepting() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	drained := s.drained
+	if drained == nil {
+		drained = make(chan struct{})
+		if s.active == 0 {
+			close(drained)
+		} else {
+			s.drained = drained
+		}
+	}
+	return drained
+}
+
+// release closes the backend session once the session has drained.
+func (s *Session) release() error {
+	s.mu.Lock()
+	bs := s.backend
+	s.backend = nil
+	s.mu.Unlock()
+	s.untrack()
+	if bs != nil {
+		return bs.Close()
+	}
+	return nil
+}
+
+// Close cancels calls in progress, waits for them to return, and releases backend resources.
+// Cancelled calls and any later calls return ErrSessionClosed. Close is idempotent.
+func (s *Session) Close() error {
+	drained := s.stopAccepting()
+	if s.haltCalls != nil {
+		s.haltCalls()
+	}
+	<-drained
+	return s.release()
+}
+
+// Shutdown stops accepting calls and waits for the calls already made, including queued ones,
+// to finish before releasing backend resources. If ctx ends first the remaining calls are
+// cancelled as by Close and Shutdown returns ctx.Err() once they have returned.
+func (s *Session) Shutdown(ctx context.Context) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	drained := s.stopAccepting()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		if s.haltCalls != nil {
+			s.haltCalls()
+		}
+		<-drained
+		err = ctx.Err()
+	}
+	return errors.Join(err, s.release())
+}
@@ -0,0 +1,152 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCloseCancelsInFlightCall(t *testing.T) {
+	backend := &stubBackend{
+		block:   make(chan struct{}),
+		respond: func(prompt string, _ GenerationOptions) (string, error) { return prompt, nil },
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := session.Respond(context.Background(), "ping")
+		errc <- err
+	}()
+	waitFor(t, session.IsResponding)
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if err := <-errc; !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
+	}
+	if backend.closes != 1 {
+		t.Fatalf("expected backend session closed once, got %d", backend.closes)
+	}
+}
+
+func TestCloseWaitsForStreamToReturn(t *testing.T) {
+	release := make(chan struct{})
+	backend := &stubBackend{
+		stream: func(_ string, _ GenerationOptions, fn func(StreamChunk)) error {
+			fn(StreamChunk{Text: "a"})
+			// Ignores cancellation, like a backend that cannot abort mid-call.
+			<-release
+			return nil
+		},
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	ch, err := session.RespondStream(context.Background(), "ping")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	<-ch
+
+	closed := make(chan error, 1)
+	go func() { closed <- session.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned while the stream was still using the backend session")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if err := <-closed; err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+	if backend.closes != 1 {
+		t.Fatalf("expected backend session closed once, got %d", backend.closes)
+	}
+	for range ch {
+	}
+}
+
+func TestShutdownDrainsCalls(t *testing.T) {
+	release := make(chan struct{})
+	backend := &stubBackend{
+		block:   release,
+		respond: func(prompt string, _ GenerationOptions) (string, error) { return prompt, nil },
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+
+	errc := make(chan error, 2)
+	for _, prompt := range []string{"first", "second"} {
+		go func() {
+			_, err := session.Respond(context.Background(), prompt)
+			errc <- err
+		}()
+	}
+	waitFor(t, func() bool { return queued(session) == 1 })
+
+	shut := make(chan error, 1)
+	go func() { shut <- session.Shutdown(context.Background()) }()
+	waitFor(t, func() bool {
+		session.mu.RLock()
+		defer session.mu.RUnlock()
+		return session.closed
+	})
+	if _, err := session.Respond(context.Background(), "late"); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from Respond, got %v", err)
+	}
+	if _, err := session.RespondStream(context.Background(), "late"); !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed from RespondStream, got %v", err)
+	}
+
+	close(release)
+	for range 2 {
+		if err := <-errc; err != nil {
+			t.Fatalf("accepted call failed: %v", err)
+		}
+	}
+	if err := <-shut; err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	if backend.closes != 1 {
+		t.Fatalf("expected backend session closed once, got %d", backend.closes)
+	}
+}
+
+func TestShutdownDeadlineCancelsCalls(t *testing.T) {
+	backend := &stubBackend{
+		block:   make(chan struct{}),
+		respond: func(prompt string, _ GenerationOptions) (string, error) { return prompt, nil },
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := session.Respond(context.Background(), "ping")
+		errc <- err
+	}()
+	waitFor(t, session.IsResponding)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := session.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if err := <-errc; !errors.Is(err, ErrSessionClosed) {
+		t.Fatalf("expected ErrSessionClosed, got %v", err)
+	}
+	if backend.closes != 1 {
+		t.Fatalf("expected backend session closed once, got %d", backend.closes)
+	}
+}
@@ -0,0 +1,143 @@
+package fundament
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// FinishReason explains why a response ended early. It is empty when the model finished the
+// response on its own. Session sets it when it cut the text itself; a marker that a server
+// applied on its own is not reported, since servers signal such a stop and a natural end
+// alike.
+type FinishReason string
+
+const (
+	// FinishStop means the output reached one of the WithStopSequences markers.
+	FinishStop FinishReason = "stop"
+	// FinishLength means the output reached the WithMaxCharacters limit.
+	FinishLength FinishReason = "length"
+)
+
+// WithStopSequences ends the response before the first occurrence of any of seqs; the marker
+// itself is not returned. Backends that support stop sequences receive them, and Session
+// enforces them for the rest, cancelling a stream as soon as a marker appears. Structured
+// responses are not affected.
+func WithStopSequences(seqs ...string) GenerationOption {
+	return func(opts *GenerationOptions) {
+		opts.StopSequences = append([]string(nil), seqs...)
+	}
+}
+
+// WithMaxCharacters caps the response at n characters (runes), e.g. to fit a UI element.
+// Like WithStopSequences it is enforced by Session and does not affect structured responses.
+func WithMaxCharacters(n int) GenerationOption {
+	return func(opts *GenerationOptions) {
+		opts.MaxCharacters = &n
+	}
+}
+
+// stopScanner applies StopSequences and MaxCharacters to text that may arrive in pieces.
+// Text that could be the start of a stop sequence is held back until the next piece decides it.
+type stopScanner struct {
+	stops    []string
+	limit    int // in runes; 0 means unlimited
+	held     string
+	emitted  int
+	reason   FinishReason
+	finished bool
+}
+
+// newStopScanner returns nil when opts set no limits.
+func newStopScanner(opts GenerationOptions) *stopScanner {
+	if len(opts.StopSequences) == 0 && opts.MaxCharacters == nil {
+		return nil
+	}
+	s := &stopScanner{stops: opts.StopSequences}
+	if opts.MaxCharacters != nil {
+		s.limit = *opts.MaxCharacters
+	}
+	return s
+}
+
+// push consumes the next piece and returns the text that may be emitted. Once done is true
+// the response is over and later pieces are ignored.
+func (s *stopScanner) push(text string) (out string, done bool) {
+	if s.finished {
+		return "", true
+	}
+	buf := s.held + text
+	s.held = ""
+	cut := -1
+	for _, stop := range s.stops {
+		if i := strings.Index(buf, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut >= 0 {
+		buf = buf[:cut]
+		s.reason, s.finished = FinishStop, true
+	} else {
+		keep := s.partialStop(buf)
+		s.held = buf[len(buf)-keep:]
+		buf = buf[:len(buf)-keep]
+	}
+	return s.limitRunes(buf), s.finished
+}
+
+// flush returns the held-back text at the end of the response.
+func (s *stopScanner) flush() string {
+	if s.finished {
+		return ""
+	}
+	buf := s.held
+	s.held = ""
+	return s.limitRunes(buf)
+}
+
+// partialStop returns the length of the longest suffix of buf that begins a stop sequence.
+func (s *stopScanner) partialStop(buf string) int {
+	longest := 0
+	for _, stop := range s.stops {
+		for n := min(len(stop)-1, len(buf)); n > longest; n-- {
+			if strings.HasPrefix(stop, buf[len(buf)-n:]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// limitRunes truncates text to the characters left under the limit.
+func (s *stopScanner) limitRunes(text string) string {
+	if s.limit <= 0 {
+		return text
+	}
+	left := s.limit - s.emitted
+	if n := utf8.RuneCountInString(text); n <= left {
+		s.emitted += n
+		return text
+	}
+	i := 0
+	for range left {
+		_, size := utf8.DecodeRuneInString(text[i:])
+		i += size
+	}
+	s.emitted = s.limit
+	s.held = ""
+	s.reason, s.finished = FinishLength, true
+	return text[:i]
+}
+
+// applyStops enforces StopSequences and MaxCharacters on a complete response.
+func applyStops(text string, opts GenerationOptions) (string, FinishReason) {
+	s := newStopScanner(opts)
+	if s == nil {
+		return text, ""
+	}
+	out, done := s.push(text)
+	if !done {
+		out += s.flush()
+	}
+	return out, s.reason
+}
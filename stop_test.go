@@ -0,0 +1,133 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStopScannerAcrossChunks(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []GenerationOption
+		chunks []string
+		want   string
+		reason FinishReason
+	}{
+		{"no match", []GenerationOption{WithStopSequences("\nUser:")}, []string{"Hello", " there\n", "Us", "ually"}, "Hello there\nUsually", ""},
+		{"split marker", []GenerationOption{WithStopSequences("\nUser:")}, []string{"Hi!\n", "Us", "er: more"}, "Hi!", FinishStop},
+		{"earliest of several", []GenerationOption{WithStopSequences("END", "--")}, []string{"a--bEND"}, "a", FinishStop},
+		{"max characters", []GenerationOption{WithMaxCharacters(4)}, []string{"héll", "o world"}, "héll", FinishLength},
+		{"limit before marker", []GenerationOption{WithMaxCharacters(1), WithStopSequences("c")}, []string{"abc"}, "a", FinishLength},
+		{"held text flushed", []GenerationOption{WithStopSequences("STOP")}, []string{"go ST"}, "go ST", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			scan := newStopScanner(resolveGenerationOptions(tc.opts))
+			var got strings.Builder
+			done := false
+			for _, chunk := range tc.chunks {
+				var out string
+				out, done = scan.push(chunk)
+				got.WriteString(out)
+				if done {
+					break
+				}
+			}
+			if !done {
+				got.WriteString(scan.flush())
+			}
+			if got.String() != tc.want || scan.reason != tc.reason {
+				t.Fatalf("got %q (%q), want %q (%q)", got.String(), scan.reason, tc.want, tc.reason)
+			}
+
+			whole, reason := applyStops(strings.Join(tc.chunks, ""), resolveGenerationOptions(tc.opts))
+			if whole != tc.want || reason != tc.reason {
+				t.Fatalf("applyStops got %q (%q), want %q (%q)", whole, reason, tc.want, tc.reason)
+			}
+		})
+	}
+}
+
+func TestRespondTruncatesAtStopSequence(t *testing.T) {
+	backend := &stubBackend{
+		respond: func(string, GenerationOptions) (string, error) {
+			return "Sure.\nUser: and then?", nil
+		},
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	resp, err := session.Respond(context.Background(), "hi", WithStopSequences("\nUser:"))
+	if err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if resp.Text != "Sure." || resp.FinishReason != FinishStop {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if entries := session.Transcript().Entries; entries[len(entries)-1].Text != "Sure." {
+		t.Fatalf("transcript must record the truncated text, got %+v", entries)
+	}
+}
+
+func TestRespondStreamStopsGeneration(t *testing.T) {
+	cancelled := make(chan struct{})
+	backend := &stubBackend{
+		streamCtx: func(ctx context.Context, fn func(StreamChunk)) error {
+			fn(StreamChunk{Text: "Sure.\nU"})
+			fn(StreamChunk{Text: "ser: and"})
+			<-ctx.Done()
+			close(cancelled)
+			fn(StreamChunk{Text: " then?", Final: true})
+			return ctx.Err()
+		},
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+
+	ch, err := session.RespondStream(context.Background(), "hi", WithStopSequences("\nUser:"))
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	var chunks []StreamChunk
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	<-cancelled
+
+	var text strings.Builder
+	for _, c := range chunks {
+		if c.Err != nil {
+			t.Fatalf("unexpected error chunk: %v", c.Err)
+		}
+		text.WriteString(c.Text)
+	}
+	last := chunks[len(chunks)-1]
+	if text.String() != "Sure." || !last.Final || last.FinishReason != FinishStop {
+		t.Fatalf("unexpected chunks %+v", chunks)
+	}
+	if session.IsResponding() {
+		t.Fatal("session must be free after the stop")
+	}
+}
+
+func TestStopOptionsValidation(t *testing.T) {
+	session, err := NewSession(SessionOptions{Backend: &stubBackend{}})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	if _, err := session.Respond(context.Background(), "hi", WithStopSequences("")); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for an empty stop sequence, got %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "hi", WithMaxCharacters(0)); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("expected ErrInvalidOption for maxCharacters 0, got %v", err)
+	}
+}
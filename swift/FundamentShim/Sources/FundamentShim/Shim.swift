@@ -45,6 +45,36 @@ private final class SessionBox: @unchecked Sendable {
 }
 #endif
 
+/// Cancels the Swift task of the call it was passed to, including a cancel that arrives before the task starts.
+private final class CancelToken: @unchecked Sendable {
+    private let lock = NSLock()
+    private var cancelled = false
+    private var task: Task<Void, Never>?
+
+    func attach(_ task: Task<Void, Never>) {
+        lock.lock()
+        self.task = task
+        let alreadyCancelled = cancelled
+        lock.unlock()
+        if alreadyCancelled {
+            task.cancel()
+        }
+    }
+
+    func cancel() {
+        lock.lock()
+        cancelled = true
+        let task = self.task
+        lock.unlock()
+        task?.cancel()
+    }
+}
+
+private func withCancelToken(_ raw: UnsafeMutableRawPointer?) -> CancelToken? {
+    guard let raw else { return nil }
+    return Unmanaged<CancelToken>.fromOpaque(raw).takeUnretainedValue()
+}
+
 // MARK: - Helpers
 
 private func duplicateCString(_ string: String) -> UnsafePointer<CChar>? {
@@ -102,9 +132,104 @@ private func storeString(_ string: String, in pointer: UnsafeMutablePointer<fund
 }
 
 #if canImport(FoundationModels)
+/// Version 1 of the options contract written by marshalGenerationOptions in options.go.
+/// Fields this shim cannot honour are reported back as warnings instead of failing the call.
+private let supportedOptionsVersion = 1
+// "stop" and "maxCharacters" are enforced on the Go side, so they are known but not read here.
+private let knownOptionKeys: Set<String> = ["v", "temperature", "topP", "topK", "maxTokens", "seed", "sampling", "stop", "maxCharacters"]
+
+private struct GenerationOptionsPayload: Decodable {
+    struct Sampling: Decodable {
+        let mode: String
+        let topK: Int?
+        let probabilityThreshold: Double?
+        let seed: UInt64?
+    }
+
+    let v: Int?
+    let temperature: Double?
+    let topP: Double?
+    let topK: Int?
+    let maxTokens: Int?
+    let seed: UInt64?
+    let sampling: Sampling?
+}
+
 @available(macOS 26.0, *)
-private func makeGenerationOptions(from _: String) -> GenerationOptions {
-    GenerationOptions()
+private func makeSamplingMode(_ payload: GenerationOptionsPayload, warnings: inout [String]) -> GenerationOptions.SamplingMode? {
+    if let sampling = payload.sampling {
+        switch sampling.mode {
+        case "greedy":
+            return .greedy
+        case "topK":
+            guard let k = sampling.topK else {
+                warnings.append("sampling mode topK without topK ignored")
+                return nil
+            }
+            return .random(top: k, seed: sampling.seed)
+        case "nucleus":
+            guard let p = sampling.probabilityThreshold else {
+                warnings.append("sampling mode nucleus without probabilityThreshold ignored")
+                return nil
+            }
+            return .random(probabilityThreshold: p, seed: sampling.seed)
+        default:
+            warnings.append("unsupported sampling mode \"\(sampling.mode)\" ignored")
+            return nil
+        }
+    }
+    // Options written without a sampling mode carry the legacy fields, which Go rejects in combination.
+    if let k = payload.topK {
+        if payload.topP != nil {
+            warnings.append("topP ignored because topK is set")
+        }
+        return .random(top: k, seed: payload.seed)
+    }
+    if let p = payload.topP {
+        return .random(probabilityThreshold: p, seed: payload.seed)
+    }
+    if payload.seed != nil {
+        warnings.append("seed ignored without topK, topP, or a random sampling mode")
+    }
+    return nil
+}
+
+@available(macOS 26.0, *)
+private func makeGenerationOptions(from json: String) -> (options: GenerationOptions, warnings: [String]) {
+    guard !json.isEmpty else { return (GenerationOptions(), []) }
+    let data = Data(json.utf8)
+    let payload: GenerationOptionsPayload
+    do {
+        payload = try JSONDecoder().decode(GenerationOptionsPayload.self, from: data)
+    } catch {
+        return (GenerationOptions(), ["options could not be decoded, defaults used: \(error.localizedDescription)"])
+    }
+    var warnings: [String] = []
+    if let v = payload.v, v > supportedOptionsVersion {
+        warnings.append("options version \(v) is newer than the shim's version \(supportedOptionsVersion)")
+    }
+    if let object = try? JSONSerialization.jsonObject(with: data) as? [String: Any] {
+        for key in object.keys.sorted() where !knownOptionKeys.contains(key) {
+            warnings.append("unsupported option \"\(key)\" ignored")
+        }
+    }
+    let options = GenerationOptions(
+        sampling: makeSamplingMode(payload, warnings: &warnings),
+        temperature: payload.temperature,
+        maximumResponseTokens: payload.maxTokens
+    )
+    return (options, warnings)
+}
+
+/// Writes the metadata reported alongside a response, or leaves the buffer empty when there is none.
+private func storeMetadata(warnings: [String], in pointer: UnsafeMutablePointer<fundament_buffer>?) {
+    guard let pointer else { return }
+    clearBuffer(pointer)
+    guard !warnings.isEmpty,
+          let data = try? JSONSerialization.data(withJSONObject: ["warnings": warnings]),
+          let json = String(data: data, encoding: .utf8)
+    else { return }
+    pointer.pointee = wrapBuffer(from: json)
 }
 
 @available(macOS 26.0, *)
@@ -115,10 +240,10 @@ private func withSessionBox(_ ref: UnsafeMutableRawPointer?) -> SessionBox? {
 
 @preconcurrency
 @available(macOS 26.0, *)
-private func performSync<T>(_ operation: @escaping @Sendable () async throws -> T) throws -> T {
+private func performSync<T>(cancel token: CancelToken? = nil, _ operation: @escaping @Sendable () async throws -> T) throws -> T {
     let semaphore = DispatchSemaphore(value: 0)
     var result: Result<T, Error> = .failure(NSError(domain: "dev.fundament.shim", code: -1, userInfo: [NSLocalizedDescriptionKey: "Unknown error"]))
-    Task {
+    let task = Task {
         do {
             let value = try await operation()
             result = .success(value)
@@ -127,6 +252,7 @@ private func performSync<T>(_ operation: @escaping @Sendable () async throws ->
         }
         semaphore.signal()
     }
+    token?.attach(task)
     semaphore.wait()
     return try result.get()
 }
@@ -174,6 +300,29 @@ public func fundament_session_create(_ instructions: UnsafePointer<CChar>?, _ ou
 #endif
 }
 
+@_cdecl("fundament_session_create_with_transcript")
+public func fundament_session_create_with_transcript(_ transcriptJSON: UnsafePointer<CChar>?, _ outError: UnsafeMutableRawPointer?) -> UnsafeMutableRawPointer? {
+#if canImport(FoundationModels)
+    let errorPtr = bindErrorPointer(outError)
+    guard #available(macOS 26.0, *) else {
+        setUnavailableError(into: errorPtr, message: "SystemLanguageModel requires macOS 26.0 or newer.")
+        return nil
+    }
+    do {
+        let transcript = try decodeTranscript(from: parseString(transcriptJSON))
+        let session = LanguageModelSession(model: .default, tools: [], transcript: transcript)
+        let box = SessionBox(session: session)
+        return Unmanaged.passRetained(box).toOpaque()
+    } catch {
+        setError(error, into: errorPtr)
+        return nil
+    }
+#else
+    setUnavailableError(into: bindErrorPointer(outError), message: "FoundationModels framework is unavailable on this platform.")
+    return nil
+#endif
+}
+
 @_cdecl("fundament_session_destroy")
 public func fundament_session_destroy(_ ref: UnsafeMutableRawPointer?) {
 #if canImport(FoundationModels)
@@ -182,6 +331,27 @@ public func fundament_session_destroy(_ ref: UnsafeMutableRawPointer?) {
 #endif
 }
 
+@_cdecl("fundament_session_prewarm")
+public func fundament_session_prewarm(_ ref: UnsafeMutableRawPointer?, _ promptPrefix: UnsafePointer<CChar>?, _ outError: UnsafeMutableRawPointer?) -> Bool {
+#if canImport(FoundationModels)
+    let errorPtr = bindErrorPointer(outError)
+    guard #available(macOS 26.0, *) else {
+        setUnavailableError(into: errorPtr, message: "SystemLanguageModel requires macOS 26.0 or newer.")
+        return false
+    }
+    guard let box = withSessionBox(ref) else {
+        setUnavailableError(into: errorPtr, message: "Invalid session handle.")
+        return false
+    }
+    let prefix = parseString(promptPrefix)
+    box.session.prewarm(promptPrefix: prefix.isEmpty ? nil : Prompt(prefix))
+    return true
+#else
+    setUnavailableError(into: bindErrorPointer(outError), message: "FoundationModels framework is unavailable on this platform.")
+    return false
+#endif
+}
+
 @_cdecl("fundament_session_check_availability")
 public func fundament_session_check_availability(_ outAvailability: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
 #if canImport(FoundationModels)
@@ -200,7 +370,7 @@ public func fundament_session_check_availability(_ outAvailability: UnsafeMutabl
 }
 
 @_cdecl("fundament_session_respond")
-public func fundament_session_respond(_ ref: UnsafeMutableRawPointer?, _ prompt: UnsafePointer<CChar>?, _ optionsJSON: UnsafePointer<CChar>?, _ outBuffer: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
+public func fundament_session_respond(_ ref: UnsafeMutableRawPointer?, _ prompt: UnsafePointer<CChar>?, _ optionsJSON: UnsafePointer<CChar>?, _ cancelToken: UnsafeMutableRawPointer?, _ outBuffer: UnsafeMutableRawPointer?, _ outMetadata: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
 #if canImport(FoundationModels)
     let bufferPtr = bindBufferPointer(outBuffer)
     let errorPtr = bindErrorPointer(outError)
@@ -216,8 +386,9 @@ public func fundament_session_respond(_ ref: UnsafeMutableRawPointer?, _ prompt:
     }
     do {
         let promptString = parseString(prompt)
-        let options = makeGenerationOptions(from: parseString(optionsJSON))
-        let response = try performSync {
+        let (options, warnings) = makeGenerationOptions(from: parseString(optionsJSON))
+        storeMetadata(warnings: warnings, in: bindBufferPointer(outMetadata))
+        let response = try performSync(cancel: withCancelToken(cancelToken)) {
             try await box.session.respond(to: promptString, options: options)
         }
         storeString(response.content, in: bufferPtr)
@@ -235,7 +406,7 @@ public func fundament_session_respond(_ ref: UnsafeMutableRawPointer?, _ prompt:
 }
 
 @_cdecl("fundament_session_respond_structured")
-public func fundament_session_respond_structured(_ ref: UnsafeMutableRawPointer?, _ prompt: UnsafePointer<CChar>?, _ schemaJSON: UnsafePointer<CChar>?, _ optionsJSON: UnsafePointer<CChar>?, _ outBuffer: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
+public func fundament_session_respond_structured(_ ref: UnsafeMutableRawPointer?, _ prompt: UnsafePointer<CChar>?, _ schemaJSON: UnsafePointer<CChar>?, _ optionsJSON: UnsafePointer<CChar>?, _ cancelToken: UnsafeMutableRawPointer?, _ outBuffer: UnsafeMutableRawPointer?, _ outMetadata: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
 #if canImport(FoundationModels)
     let bufferPtr = bindBufferPointer(outBuffer)
     let errorPtr = bindErrorPointer(outError)
@@ -252,8 +423,9 @@ public func fundament_session_respond_structured(_ ref: UnsafeMutableRawPointer?
     do {
         let promptString = parseString(prompt)
         let schemaString = parseString(schemaJSON)
-        let options = makeGenerationOptions(from: parseString(optionsJSON))
-        let response = try performSync {
+        let (options, warnings) = makeGenerationOptions(from: parseString(optionsJSON))
+        storeMetadata(warnings: warnings, in: bindBufferPointer(outMetadata))
+        let response = try performSync(cancel: withCancelToken(cancelToken)) {
             let schema = try decodeSchema(from: schemaString)
             return try await box.session.respond(to: promptString, schema: schema, includeSchemaInPrompt: true, options: options)
         }
@@ -274,7 +446,7 @@ public func fundament_session_respond_structured(_ ref: UnsafeMutableRawPointer?
 }
 
 @_cdecl("fundament_session_stream")
-public func fundament_session_stream(_ ref: UnsafeMutableRawPointer?, _ prompt: UnsafePointer<CChar>?, _ optionsJSON: UnsafePointer<CChar>?, _ callback: fundament_stream_cb?, _ userData: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
+public func fundament_session_stream(_ ref: UnsafeMutableRawPointer?, _ prompt: UnsafePointer<CChar>?, _ optionsJSON: UnsafePointer<CChar>?, _ cancelToken: UnsafeMutableRawPointer?, _ callback: fundament_stream_cb?, _ userData: UnsafeMutableRawPointer?, _ outMetadata: UnsafeMutableRawPointer?, _ outError: UnsafeMutableRawPointer?) -> Bool {
 #if canImport(FoundationModels)
     let errorPtr = bindErrorPointer(outError)
     guard #available(macOS 26.0, *) else {
@@ -290,10 +462,11 @@ public func fundament_session_stream(_ ref: UnsafeMutableRawPointer?, _ prompt:
         return false
     }
     let promptString = parseString(prompt)
-    let options = makeGenerationOptions(from: parseString(optionsJSON))
+    let (options, warnings) = makeGenerationOptions(from: parseString(optionsJSON))
+    storeMetadata(warnings: warnings, in: bindBufferPointer(outMetadata))
     let streamContext = StreamContext(userData: userData)
     do {
-        _ = try performSync {
+        _ = try performSync(cancel: withCancelToken(cancelToken)) {
             let stream = box.session.streamResponse(to: promptString, options: options)
             let response = try await stream.collect()
             try await callStreamingCallback(with: response.content, callback: callback, userData: streamContext.userData)
@@ -310,6 +483,22 @@ public func fundament_session_stream(_ ref: UnsafeMutableRawPointer?, _ prompt:
 #endif
 }
 
+@_cdecl("fundament_cancel_token_create")
+public func fundament_cancel_token_create() -> UnsafeMutableRawPointer? {
+    Unmanaged.passRetained(CancelToken()).toOpaque()
+}
+
+@_cdecl("fundament_cancel_token_cancel")
+public func fundament_cancel_token_cancel(_ raw: UnsafeMutableRawPointer?) {
+    withCancelToken(raw)?.cancel()
+}
+
+@_cdecl("fundament_cancel_token_destroy")
+public func fundament_cancel_token_destroy(_ raw: UnsafeMutableRawPointer?) {
+    guard let raw else { return }
+    Unmanaged<CancelToken>.fromOpaque(raw).release()
+}
+
 @_cdecl("fundament_buffer_free")
 public func fundament_buffer_free(_ raw: UnsafeMutableRawPointer?) {
 #if canImport(FoundationModels)
@@ -344,15 +533,39 @@ private final class SchemaNode: Decodable {
     let minimumElements: Int?
     let maximumElements: Int?
     let anyOf: [String]?
+    let minimum: Double?
+    let maximum: Double?
+    let pattern: String?
+    let const: String?
+    let ref: String?
+    let definitions: [String: SchemaNode]?
+
+    enum CodingKeys: String, CodingKey {
+        case name, description, type, properties, items, minimumElements, maximumElements, anyOf
+        case minimum, maximum, pattern, const
+        case ref = "$ref"
+        case definitions
+    }
 }
 
+private let definitionsPrefix = "#/definitions/"
+
 @available(macOS 26.0, *)
-private func buildDynamicSchema(from node: SchemaNode) throws -> DynamicGenerationSchema {
+/// Builds the schema for a node. `definitionName` names a node registered as a dependency,
+/// which references resolve against.
+private func buildDynamicSchema(from node: SchemaNode, definitionName: String? = nil) throws -> DynamicGenerationSchema {
+    if let ref = node.ref {
+        guard ref.hasPrefix(definitionsPrefix), ref.count > definitionsPrefix.count else {
+            throw NSError(domain: "dev.fundament.shim", code: -11, userInfo: [NSLocalizedDescriptionKey: "Unsupported reference '\(ref)'"])
+        }
+        return DynamicGenerationSchema(referenceTo: String(ref.dropFirst(definitionsPrefix.count)))
+    }
+
     if let properties = node.properties, !properties.isEmpty {
         let dynamicProperties = try properties.map {
-            DynamicGenerationSchema.Property(name: $0.name, schema: try buildDynamicSchema(from: $0.schema))
+            DynamicGenerationSchema.Property(name: $0.name, description: $0.schema.description, schema: try buildDynamicSchema(from: $0.schema))
         }
-        return DynamicGenerationSchema(name: node.name ?? "Object", description: node.description, properties: dynamicProperties)
+        return DynamicGenerationSchema(name: definitionName ?? node.name ?? "Object", description: node.description, properties: dynamicProperties)
     }
 
     if node.type == "array" {
@@ -366,11 +579,40 @@ private func buildDynamicSchema(from node: SchemaNode) throws -> DynamicGenerati
     switch node.type {
     case "string", nil:
         if let anyOf = node.anyOf {
-            return DynamicGenerationSchema(name: node.name ?? "String", description: node.description, anyOf: anyOf)
+            return DynamicGenerationSchema(name: definitionName ?? node.name ?? "String", description: node.description, anyOf: anyOf)
+        }
+        var guides: [GenerationGuide<String>] = []
+        if let constant = node.const {
+            guides.append(.constant(constant))
+        }
+        if let pattern = node.pattern {
+            let regex: Regex<AnyRegexOutput>
+            do {
+                regex = try Regex(pattern)
+            } catch {
+                throw NSError(domain: "dev.fundament.shim", code: -10, userInfo: [NSLocalizedDescriptionKey: "Invalid pattern '\(pattern)': \(error)"])
+            }
+            guides.append(.pattern(regex))
         }
-        return DynamicGenerationSchema(type: String.self, guides: [])
+        return DynamicGenerationSchema(type: String.self, guides: guides)
     case "integer":
-        return DynamicGenerationSchema(type: Int.self, guides: [])
+        var guides: [GenerationGuide<Int>] = []
+        if let minimum = node.minimum {
+            guides.append(.minimum(Int(minimum)))
+        }
+        if let maximum = node.maximum {
+            guides.append(.maximum(Int(maximum)))
+        }
+        return DynamicGenerationSchema(type: Int.self, guides: guides)
+    case "number", "double":
+        var guides: [GenerationGuide<Double>] = []
+        if let minimum = node.minimum {
+            guides.append(.minimum(minimum))
+        }
+        if let maximum = node.maximum {
+            guides.append(.maximum(maximum))
+        }
+        return DynamicGenerationSchema(type: Double.self, guides: guides)
     case "boolean":
         return DynamicGenerationSchema(type: Bool.self, guides: [])
     default:
@@ -392,7 +634,48 @@ private func decodeSchema(from json: String) throws -> GenerationSchema {
     let decoder = JSONDecoder()
     let node = try decoder.decode(SchemaNode.self, from: data)
     let root = try buildDynamicSchema(from: node)
-    return try GenerationSchema(root: root, dependencies: [])
+    let dependencies = try (node.definitions ?? [:]).sorted { $0.key < $1.key }.map {
+        try buildDynamicSchema(from: $0.value, definitionName: $0.key)
+    }
+    return try GenerationSchema(root: root, dependencies: dependencies)
+}
+
+@available(macOS 26.0, *)
+private func decodeTranscript(from json: String) throws -> Transcript {
+    let invalid = NSError(domain: "dev.fundament.shim", code: -8, userInfo: [NSLocalizedDescriptionKey: "Transcript JSON must hold an 'entries' array"])
+    guard let root = try JSONSerialization.jsonObject(with: Data(json.utf8)) as? [String: Any] else { throw invalid }
+    guard let payload = root["entries"] as? [[String: Any]] else { throw invalid }
+    var entries: [Transcript.Entry] = []
+    for entry in payload {
+        let kind = entry["kind"] as? String ?? ""
+        let toolName = entry["toolName"] as? String ?? ""
+        let toolCallID = entry["toolCallId"] as? String ?? UUID().uuidString
+        let text = Transcript.Segment.text(Transcript.TextSegment(content: entry["text"] as? String ?? ""))
+        func content() throws -> GeneratedContent {
+            guard let value = entry["json"] else { return try GeneratedContent(json: "{}") }
+            let data = try JSONSerialization.data(withJSONObject: value, options: [.fragmentsAllowed])
+            return try GeneratedContent(json: String(decoding: data, as: UTF8.self))
+        }
+        switch kind {
+        case "instructions":
+            entries.append(.instructions(Transcript.Instructions(segments: [text], toolDefinitions: [])))
+        case "prompt":
+            entries.append(.prompt(Transcript.Prompt(segments: [text])))
+        case "response":
+            entries.append(.response(Transcript.Response(assetIDs: [], segments: [text])))
+        case "structuredResponse":
+            let segment = Transcript.Segment.structure(Transcript.StructuredSegment(source: "fundament", content: try content()))
+            entries.append(.response(Transcript.Response(assetIDs: [], segments: [segment])))
+        case "toolCall":
+            let call = Transcript.ToolCall(id: toolCallID, toolName: toolName, arguments: try content())
+            entries.append(.toolCalls(Transcript.ToolCalls([call])))
+        case "toolOutput":
+            entries.append(.toolOutput(Transcript.ToolOutput(id: toolCallID, toolName: toolName, segments: [text])))
+        default:
+            throw NSError(domain: "dev.fundament.shim", code: -9, userInfo: [NSLocalizedDescriptionKey: "Unsupported transcript entry '\(kind)'"])
+        }
+    }
+    return Transcript(entries: entries)
 }
 
 @available(macOS 26.0, *)
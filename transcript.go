@@ -0,0 +1,104 @@
+package fundament
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// TranscriptEntryKind identifies the role of a TranscriptEntry.
+type TranscriptEntryKind string
+
+const (
+	EntryInstructions       TranscriptEntryKind = "instructions"
+	EntryPrompt             TranscriptEntryKind = "prompt"
+	EntryResponse           TranscriptEntryKind = "response"
+	EntryStructuredResponse TranscriptEntryKind = "structuredResponse"
+	EntryToolCall           TranscriptEntryKind = "toolCall"
+	EntryToolOutput         TranscriptEntryKind = "toolOutput"
+)
+
+// TranscriptEntry is one turn of a conversation. Which fields are set depends on Kind:
+// Text holds instructions, prompts, responses, and tool output; JSON and Schema hold a
+// structured response and the schema that guided it; ToolName, ToolCallID, and JSON
+// (the arguments) describe tool calls.
+type TranscriptEntry struct {
+	Kind       TranscriptEntryKind `json:"kind"`
+	Text       string              `json:"text,omitempty"`
+	JSON       json.RawMessage     `json:"json,omitempty"`
+	Schema     json.RawMessage     `json:"schema,omitempty"`
+	ToolName   string              `json:"toolName,omitempty"`
+	ToolCallID string              `json:"toolCallId,omitempty"`
+}
+
+// Transcript is the ordered history of a Session. It marshals to JSON, so conversations can be
+// persisted and restored through SessionOptions.Transcript.
+type Transcript struct {
+	Entries []TranscriptEntry `json:"entries"`
+}
+
+// Instructions returns the text of the leading instructions entry, if any.
+func (t Transcript) Instructions() string {
+	if len(t.Entries) > 0 && t.Entries[0].Kind == EntryInstructions {
+		return t.Entries[0].Text
+	}
+	return ""
+}
+
+// Validate checks that every entry has a known kind and the fields that kind requires,
+// and that instructions only appear first.
+func (t Transcript) Validate() error {
+	for i, e := range t.Entries {
+		switch e.Kind {
+		case EntryInstructions:
+			if i != 0 {
+				return fmt.Errorf("fundament: transcript entry %d: instructions must be the first entry", i)
+			}
+		case EntryPrompt, EntryResponse:
+		case EntryStructuredResponse:
+			if !json.Valid(e.JSON) {
+				return fmt.Errorf("fundament: transcript entry %d: structured response must hold valid JSON", i)
+			}
+		case EntryToolCall:
+			if e.ToolName == "" {
+				return fmt.Errorf("fundament: transcript entry %d: tool call requires a tool name", i)
+			}
+			if len(e.JSON) > 0 && !json.Valid(e.JSON) {
+				return fmt.Errorf("fundament: transcript entry %d: tool call arguments must be valid JSON", i)
+			}
+		case EntryToolOutput:
+			if e.ToolName == "" {
+				return fmt.Errorf("fundament: transcript entry %d: tool output requires a tool name", i)
+			}
+		default:
+			return fmt.Errorf("fundament: transcript entry %d: unknown kind %q", i, e.Kind)
+		}
+	}
+	return nil
+}
+
+// clone copies t deeply enough that neither copy can change the other, including the bytes
+// behind JSON and Schema.
+func (t Transcript) clone() Transcript {
+	entries := append([]TranscriptEntry(nil), t.Entries...)
+	for i := range entries {
+		entries[i].JSON = cloneRaw(entries[i].JSON)
+		entries[i].Schema = cloneRaw(entries[i].Schema)
+	}
+	return Transcript{Entries: entries}
+}
+
+func cloneRaw(raw json.RawMessage) json.RawMessage {
+	if raw == nil {
+		return nil
+	}
+	return append(json.RawMessage{}, raw...)
+}
+
+// TranscriptBackend is implemented by backends that can start a session from an existing
+// transcript. SessionOptions.Transcript requires it.
+type TranscriptBackend interface {
+	NewSessionFromTranscript(transcript Transcript) (BackendSession, error)
+}
+
+var errTranscriptUnsupported = errors.New("fundament: backend cannot restore a transcript")
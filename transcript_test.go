@@ -0,0 +1,160 @@
+package fundament_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/domano/fundament"
+	"github.com/domano/fundament/fundamenttest"
+)
+
+func TestSessionTranscriptRecordsCompletedTurns(t *testing.T) {
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Exact("broken")).Fail(errors.New("boom"))
+	model.On(fundamenttest.Exact("stream")).Stream("Hel", "lo")
+	model.On(fundamenttest.Exact("city")).ReplyJSON(`{"city":"Berlin"}`)
+	model.On(fundamenttest.Any()).Reply("pong")
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{Instructions: "be brief"})
+
+	schema, err := fundament.SchemaFromRawJSON([]byte(`{"name":"City","properties":[{"name":"city","schema":{"type":"string"}}]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "ping"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	if _, err := session.Respond(context.Background(), "broken"); err == nil {
+		t.Fatal("expected error")
+	}
+	if _, err := session.RespondStructured(context.Background(), "city", schema); err != nil {
+		t.Fatalf("RespondStructured error: %v", err)
+	}
+	ch, err := session.RespondStream(context.Background(), "stream")
+	if err != nil {
+		t.Fatalf("RespondStream error: %v", err)
+	}
+	for range ch {
+	}
+
+	got := session.Transcript()
+	want := []fundament.TranscriptEntry{
+		{Kind: fundament.EntryInstructions, Text: "be brief"},
+		{Kind: fundament.EntryPrompt, Text: "ping"},
+		{Kind: fundament.EntryResponse, Text: "pong"},
+		{Kind: fundament.EntryPrompt, Text: "city"},
+		{Kind: fundament.EntryStructuredResponse, JSON: json.RawMessage(`{"city":"Berlin"}`), Schema: schema.Raw()},
+		{Kind: fundament.EntryPrompt, Text: "stream"},
+		{Kind: fundament.EntryResponse, Text: "Hello"},
+	}
+	if len(got.Entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), got.Entries)
+	}
+	for i := range want {
+		g, w := got.Entries[i], want[i]
+		if g.Kind != w.Kind || g.Text != w.Text || string(g.JSON) != string(w.JSON) || string(g.Schema) != string(w.Schema) {
+			t.Fatalf("entry %d = %+v, want %+v", i, g, w)
+		}
+	}
+
+	got.Entries[0].Text = "mutated"
+	got.Entries[4].JSON[2] = 'X'
+	got.Entries[4].Schema[2] = 'X'
+	again := session.Transcript()
+	if again.Entries[0].Text != "be brief" || string(again.Entries[4].JSON) != `{"city":"Berlin"}` || string(again.Entries[4].Schema) != string(schema.Raw()) {
+		t.Fatal("Transcript must return a copy")
+	}
+}
+
+func TestSessionRestoresTranscript(t *testing.T) {
+	data := []byte(`{"entries":[
+		{"kind":"instructions","text":"be brief"},
+		{"kind":"prompt","text":"my name is Ada"},
+		{"kind":"response","text":"Hi Ada"},
+		{"kind":"toolCall","toolName":"lookup","toolCallId":"1","json":{"q":"Ada"}},
+		{"kind":"toolOutput","toolName":"lookup","toolCallId":"1","text":"found"}
+	]}`)
+	var transcript fundament.Transcript
+	if err := json.Unmarshal(data, &transcript); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+
+	model := fundamenttest.NewModel()
+	model.On(fundamenttest.Any()).Reply("Your name is Ada")
+	session := fundamenttest.NewSession(t, model, fundament.SessionOptions{Transcript: &transcript})
+
+	if session.Instructions() != "be brief" {
+		t.Fatalf("instructions not taken from transcript: %q", session.Instructions())
+	}
+	if _, err := session.Respond(context.Background(), "what is my name?"); err != nil {
+		t.Fatalf("Respond error: %v", err)
+	}
+	calls := model.Calls()
+	if len(calls) != 1 || calls[0].Instructions != "be brief" || len(calls[0].Transcript.Entries) != 5 {
+		t.Fatalf("backend not seeded with transcript: %+v", calls)
+	}
+	if n := len(session.Transcript().Entries); n != 7 {
+		t.Fatalf("expected restored entries plus the new turn, got %d", n)
+	}
+
+	encoded, err := json.Marshal(session.Transcript())
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	var decoded fundament.Transcript
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if len(decoded.Entries) != 7 || decoded.Entries[3].ToolName != "lookup" || string(decoded.Entries[3].JSON) != `{"q":"Ada"}` {
+		t.Fatalf("round trip lost data: %s", encoded)
+	}
+}
+
+func TestSessionTranscriptOptionErrors(t *testing.T) {
+	model := fundamenttest.NewModel()
+	seeded := &fundament.Transcript{Entries: []fundament.TranscriptEntry{{Kind: fundament.EntryInstructions, Text: "a"}}}
+
+	if _, err := fundament.NewSession(fundament.SessionOptions{Instructions: "b", Transcript: seeded, Backend: model}); err == nil {
+		t.Fatal("expected conflicting instructions to fail")
+	}
+	session, err := fundament.NewSession(fundament.SessionOptions{Instructions: "a", Transcript: seeded, Backend: model})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	session.Close()
+
+	invalid := &fundament.Transcript{Entries: []fundament.TranscriptEntry{{Kind: fundament.EntryPrompt}, {Kind: fundament.EntryInstructions}}}
+	if _, err := fundament.NewSession(fundament.SessionOptions{Transcript: invalid, Backend: model}); err == nil {
+		t.Fatal("expected misplaced instructions to fail validation")
+	}
+
+	type plainBackend struct{ fundament.Backend }
+	if _, err := fundament.NewSession(fundament.SessionOptions{Transcript: seeded, Backend: plainBackend{model}}); err == nil {
+		t.Fatal("expected error for backend without transcript support")
+	}
+	if model.OpenSessions() != 0 {
+		t.Fatalf("sessions leaked: %d", model.OpenSessions())
+	}
+}
+
+func TestTranscriptValidate(t *testing.T) {
+	cases := []struct {
+		name  string
+		entry fundament.TranscriptEntry
+		ok    bool
+	}{
+		{"prompt", fundament.TranscriptEntry{Kind: fundament.EntryPrompt, Text: "hi"}, true},
+		{"structured", fundament.TranscriptEntry{Kind: fundament.EntryStructuredResponse, JSON: json.RawMessage(`{}`)}, true},
+		{"structured without json", fundament.TranscriptEntry{Kind: fundament.EntryStructuredResponse}, false},
+		{"tool call without name", fundament.TranscriptEntry{Kind: fundament.EntryToolCall}, false},
+		{"tool output", fundament.TranscriptEntry{Kind: fundament.EntryToolOutput, ToolName: "lookup"}, true},
+		{"unknown", fundament.TranscriptEntry{Kind: "image"}, false},
+	}
+	for _, tc := range cases {
+		err := fundament.Transcript{Entries: []fundament.TranscriptEntry{tc.entry}}.Validate()
+		if (err == nil) != tc.ok {
+			t.Fatalf("%s: Validate() = %v, want ok=%v", tc.name, err, tc.ok)
+		}
+	}
+}
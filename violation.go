@@ -0,0 +1,206 @@
+package fundament
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// SchemaViolation is one way a structured response departs from its Schema.
+type SchemaViolation struct {
+	// Pointer is the JSON pointer (RFC 6901) of the offending value within the response,
+	// empty for the root.
+	Pointer string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	if v.Pointer == "" {
+		return "(root): " + v.Message
+	}
+	return v.Pointer + ": " + v.Message
+}
+
+// SchemaViolationError is returned by RespondStructured when the response does not match the
+// requested Schema. JSON holds the response so callers can inspect it before retrying.
+type SchemaViolationError struct {
+	Violations []SchemaViolation
+	JSON       json.RawMessage
+}
+
+func (e *SchemaViolationError) Error() string {
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.String()
+	}
+	return "fundament: response violates schema: " + strings.Join(parts, "; ")
+}
+
+// WithoutResponseValidation skips checking structured responses against their Schema, e.g.
+// for backends whose output is known to drift in ways the caller tolerates.
+func WithoutResponseValidation() GenerationOption {
+	return func(opts *GenerationOptions) {
+		opts.SkipResponseValidation = true
+	}
+}
+
+// CheckResponse reports how data departs from the schema: missing or unexpected properties,
+// array lengths outside minimumElements and maximumElements, numbers outside minimum and
+// maximum, strings outside anyOf or not matching pattern or const, and values of the wrong
+// type. It returns nil when data conforms and a *SchemaViolationError otherwise.
+func (s Schema) CheckResponse(data []byte) error {
+	node, err := s.root()
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return fmt.Errorf("fundament: decode response: %w", err)
+	}
+	c := &responseChecker{defs: node.Definitions}
+	c.value(node, value, "", 0)
+	if len(c.violations) > 0 {
+		return &SchemaViolationError{Violations: c.violations, JSON: append(json.RawMessage(nil), data...)}
+	}
+	return nil
+}
+
+// responseChecker walks a response alongside its schema. depth counts the references
+// followed to reach the current value.
+type responseChecker struct {
+	defs       map[string]schemaNode
+	violations []SchemaViolation
+}
+
+func (c *responseChecker) value(node schemaNode, value any, pointer string, depth int) {
+	report := func(format string, args ...any) {
+		c.violations = append(c.violations, SchemaViolation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if node.Ref != "" {
+		name, _ := definitionName(node.Ref)
+		def, ok := c.defs[name]
+		switch {
+		case !ok:
+			report("schema refers to unknown definition %q", node.Ref)
+		case depth >= maxSchemaDepth:
+			report("nesting exceeds %d levels of %q", maxSchemaDepth, name)
+		default:
+			c.value(def, value, pointer, depth+1)
+		}
+		return
+	}
+
+	if len(node.Properties) > 0 {
+		object, ok := value.(map[string]any)
+		if !ok {
+			report("expected object, got %s", jsonKind(value))
+			return
+		}
+		for _, prop := range node.Properties {
+			child, ok := object[prop.Name]
+			if !ok {
+				report("missing property %q", prop.Name)
+				continue
+			}
+			c.value(prop.Schema, child, pointer+"/"+escapePointer(prop.Name), depth)
+		}
+		var extra []string
+		for key := range object {
+			if !slices.ContainsFunc(node.Properties, func(p schemaProperty) bool { return p.Name == key }) {
+				extra = append(extra, key)
+			}
+		}
+		slices.Sort(extra)
+		for _, key := range extra {
+			report("unexpected property %q", key)
+		}
+		return
+	}
+
+	switch node.Type {
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			report("expected array, got %s", jsonKind(value))
+			return
+		}
+		if node.MinimumElements != nil && len(items) < *node.MinimumElements {
+			report("expected at least %d elements, got %d", *node.MinimumElements, len(items))
+		}
+		if node.MaximumElements != nil && len(items) > *node.MaximumElements {
+			report("expected at most %d elements, got %d", *node.MaximumElements, len(items))
+		}
+		if node.Items != nil {
+			for i, item := range items {
+				c.value(*node.Items, item, pointer+"/"+strconv.Itoa(i), depth)
+			}
+		}
+	case "integer", "number", "double":
+		n, ok := value.(json.Number)
+		if !ok {
+			report("expected %s, got %s", node.Type, jsonKind(value))
+			return
+		}
+		f, err := n.Float64()
+		if err != nil || (node.Type == "integer" && f != math.Trunc(f)) {
+			report("expected %s, got %s", node.Type, n)
+			return
+		}
+		if node.Minimum != nil && f < *node.Minimum {
+			report("%s is less than the minimum %v", n, *node.Minimum)
+		}
+		if node.Maximum != nil && f > *node.Maximum {
+			report("%s is greater than the maximum %v", n, *node.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			report("expected boolean, got %s", jsonKind(value))
+		}
+	default:
+		str, ok := value.(string)
+		if !ok {
+			report("expected string, got %s", jsonKind(value))
+			return
+		}
+		if node.AnyOf != nil && !slices.Contains(node.AnyOf, str) {
+			report("%q is not one of %q", str, node.AnyOf)
+		}
+		if node.Const != nil && str != *node.Const {
+			report("expected %q, got %q", *node.Const, str)
+		}
+		if node.Pattern != "" {
+			if re, err := compilePattern(node.Pattern); err == nil && !re.MatchString(str) {
+				report("%q does not match pattern %q", str, node.Pattern)
+			}
+		}
+	}
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return "number"
+	}
+}
+
+// escapePointer escapes a property name for use as a JSON pointer token.
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
@@ -0,0 +1,132 @@
+package fundament
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const violationSchema = `{"name":"Plan","properties":[
+	{"name":"destination","schema":{"type":"string"}},
+	{"name":"season","schema":{"anyOf":["spring","autumn"]}},
+	{"name":"days","schema":{"type":"integer"}},
+	{"name":"highlights","schema":{"type":"array","minimumElements":2,"maximumElements":3,"items":{"type":"string"}}},
+	{"name":"stops","schema":{"type":"array","items":{"name":"Stop","properties":[
+		{"name":"a/b","schema":{"type":"boolean"}}
+	]}}}
+]}`
+
+func TestCheckResponseViolations(t *testing.T) {
+	schema, err := SchemaFromRawJSON([]byte(violationSchema))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if err := schema.CheckResponse([]byte(`{"destination":"Kyoto","season":"autumn","days":2,"highlights":["a","b"],"stops":[{"a/b":true}]}`)); err != nil {
+		t.Fatalf("conforming response rejected: %v", err)
+	}
+
+	err = schema.CheckResponse([]byte(`{"season":"winter","days":2.5,"highlights":["a"],"stops":[{"a/b":"yes"},null],"extra":1}`))
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected *SchemaViolationError, got %v", err)
+	}
+	want := []SchemaViolation{
+		{Pointer: "", Message: `missing property "destination"`},
+		{Pointer: "/season", Message: `"winter" is not one of ["spring" "autumn"]`},
+		{Pointer: "/days", Message: "expected integer, got 2.5"},
+		{Pointer: "/highlights", Message: "expected at least 2 elements, got 1"},
+		{Pointer: "/stops/0/a~1b", Message: "expected boolean, got string"},
+		{Pointer: "/stops/1", Message: "expected object, got null"},
+		{Pointer: "", Message: `unexpected property "extra"`},
+	}
+	if !reflect.DeepEqual(violation.Violations, want) {
+		t.Fatalf("violations = %+v\nwant %+v", violation.Violations, want)
+	}
+}
+
+func TestRespondStructuredValidatesResponse(t *testing.T) {
+	backend := &stubBackend{
+		respondStructured: func(string, Schema, GenerationOptions) (string, error) {
+			return `{"destination":"Kyoto","season":"autumn","days":"two","highlights":["a","b"],"stops":[]}`, nil
+		},
+	}
+	session, err := NewSession(SessionOptions{Backend: backend})
+	if err != nil {
+		t.Fatalf("NewSession error: %v", err)
+	}
+	defer session.Close()
+	schema, _ := SchemaFromRawJSON([]byte(violationSchema))
+
+	_, err = session.RespondStructured(context.Background(), "plan", schema)
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) || len(violation.Violations) != 1 || violation.Violations[0].Pointer != "/days" {
+		t.Fatalf("expected a violation at /days, got %v", err)
+	}
+	if len(violation.JSON) == 0 {
+		t.Fatal("violation must carry the response JSON")
+	}
+
+	resp, err := session.RespondStructured(context.Background(), "plan", schema, WithoutResponseValidation())
+	if err != nil || len(resp.JSON) == 0 {
+		t.Fatalf("WithoutResponseValidation must return the response, got %v", err)
+	}
+}
+
+func TestCheckResponseGuides(t *testing.T) {
+	schema, err := SchemaFromRawJSON([]byte(`{"name":"Review","properties":[
+		{"name":"rating","schema":{"type":"integer","minimum":1,"maximum":5}},
+		{"name":"price","schema":{"type":"number","minimum":0}},
+		{"name":"sku","schema":{"type":"string","pattern":"[A-Z]{3}-\\d+"}},
+		{"name":"kind","schema":{"type":"string","const":"review"}}
+	]}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if err := schema.CheckResponse([]byte(`{"rating":5,"price":9.5,"sku":"ABC-12","kind":"review"}`)); err != nil {
+		t.Fatalf("conforming response rejected: %v", err)
+	}
+
+	err = schema.CheckResponse([]byte(`{"rating":6,"price":-0.5,"sku":"ABC-12x","kind":"note"}`))
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("expected *SchemaViolationError, got %v", err)
+	}
+	want := []SchemaViolation{
+		{Pointer: "/rating", Message: "6 is greater than the maximum 5"},
+		{Pointer: "/price", Message: "-0.5 is less than the minimum 0"},
+		{Pointer: "/sku", Message: `"ABC-12x" does not match pattern "[A-Z]{3}-\\d+"`},
+		{Pointer: "/kind", Message: `expected "review", got "note"`},
+	}
+	if !reflect.DeepEqual(violation.Violations, want) {
+		t.Fatalf("violations = %+v\nwant %+v", violation.Violations, want)
+	}
+}
+
+func TestCheckResponseFollowsReferences(t *testing.T) {
+	schema, err := SchemaFromRawJSON([]byte(`{"$ref":"#/definitions/Node","definitions":{
+		"Node":{"name":"Node","properties":[
+			{"name":"value","schema":{"type":"integer"}},
+			{"name":"children","schema":{"type":"array","items":{"$ref":"#/definitions/Node"}}}
+		]}
+	}}`))
+	if err != nil {
+		t.Fatalf("SchemaFromRawJSON error: %v", err)
+	}
+	if err := schema.CheckResponse([]byte(`{"value":1,"children":[{"value":2,"children":[]}]}`)); err != nil {
+		t.Fatalf("conforming response rejected: %v", err)
+	}
+
+	err = schema.CheckResponse([]byte(`{"value":1,"children":[{"value":"two","children":[]}]}`))
+	var violation *SchemaViolationError
+	if !errors.As(err, &violation) || violation.Violations[0].Pointer != "/children/0/value" {
+		t.Fatalf("expected a violation at /children/0/value, got %v", err)
+	}
+
+	deep := strings.Repeat(`{"value":0,"children":[`, maxSchemaDepth+1) + strings.Repeat(`]}`, maxSchemaDepth+1)
+	err = schema.CheckResponse([]byte(deep))
+	if !errors.As(err, &violation) || !strings.Contains(violation.Violations[0].Message, "nesting exceeds") {
+		t.Fatalf("expected the depth limit to stop the check, got %v", err)
+	}
+}